@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/StatCan/ingress-istio-controller/pkg/controller"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Key of the configuration file holding the annotation defaults.
+const annotationDefaultsKey = "annotation-defaults"
+
+// How often the configuration file is checked for changes.
+const configReloadInterval = 10 * time.Second
+
+// reloadableFlags are the flags which are applied to the controller when the configuration file changes.
+// The other flags of the configuration file only take effect on startup.
+var reloadableFlags = []string{
+	"cluster-domain",
+	"default-gateway",
+	"scoped-gateways",
+	"publish-status-address",
+	"publish-service",
+	"ingress-class",
+	"virtual-service-weight",
+	"virtual-service-export-to",
+	"disable-rate-limiting",
+}
+
+// commandLineFlags are the flags set on the command line, which take precedence over the configuration file.
+var commandLineFlags = map[string]bool{}
+
+// annotationDefaults are the annotation defaults of the configuration file.
+var annotationDefaults map[string]string
+
+// loadConfig reads the configuration file, whose keys are the names of the flags, into the flags.
+// On reload, only the reloadable flags are changed. If the configuration is invalid,
+// the flags are left unchanged and an error is returned.
+func loadConfig(path string, reload bool) (controller.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return controller.Config{}, err
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return controller.Config{}, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	// Keep the current values, to be restored if the configuration is invalid
+	previous := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		previous[f.Name] = f.Value.String()
+	})
+	previousAnnotationDefaults := annotationDefaults
+
+	restore := func() {
+		for name, val := range previous {
+			flag.Set(name, val)
+		}
+		annotationDefaults = previousAnnotationDefaults
+	}
+
+	// Settings removed from the configuration file return to their default value
+	if reload {
+		for _, name := range reloadableFlags {
+			if !commandLineFlags[name] {
+				flag.Set(name, flag.Lookup(name).DefValue)
+			}
+		}
+	}
+
+	annotationDefaults = nil
+	for key, value := range values {
+		if key == annotationDefaultsKey {
+			annotationDefaults, err = parseAnnotationDefaults(value)
+			if err != nil {
+				restore()
+				return controller.Config{}, err
+			}
+			continue
+		}
+
+		f := flag.Lookup(key)
+		if f == nil || key == "config" {
+			restore()
+			return controller.Config{}, fmt.Errorf("unknown setting %q in %s", key, path)
+		}
+
+		val := configValueString(value)
+
+		if commandLineFlags[key] {
+			klog.InfoS("setting of the configuration file is overridden by the command line", "setting", key)
+			continue
+		}
+
+		if reload && !stringInSlice(key, reloadableFlags) {
+			if val != previous[key] {
+				klog.InfoS("setting of the configuration file requires a restart to take effect", "setting", key)
+			}
+			continue
+		}
+
+		if err := f.Value.Set(val); err != nil {
+			restore()
+			return controller.Config{}, fmt.Errorf("invalid value %q for %q in %s: %v", val, key, path, err)
+		}
+	}
+
+	cfg := currentConfig()
+	if err := cfg.Validate(); err != nil {
+		restore()
+		return controller.Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// currentConfig returns the runtime settings of the controller from the flags.
+func currentConfig() controller.Config {
+	return controller.Config{
+		ClusterDomain:        clusterDomain,
+		DefaultGateway:       defaultGateway,
+		ScopedGateways:       scopedGateways,
+		PublishStatusAddress: publishStatusAddress,
+		PublishService:       publishService,
+		IngressClass:         ingressClass,
+		DefaultWeight:        defaultWeight,
+		DefaultExportTo:      defaultExportTo,
+		DisableRateLimiting:  disableRateLimiting,
+		AnnotationDefaults:   annotationDefaults,
+	}
+}
+
+// parseAnnotationDefaults parses the annotation defaults of the configuration file.
+func parseAnnotationDefaults(value interface{}) (map[string]string, error) {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s: expected a map of annotations to values", annotationDefaultsKey)
+	}
+
+	defaults := map[string]string{}
+	for key, val := range values {
+		defaults[key] = configValueString(val)
+	}
+
+	return defaults, nil
+}
+
+// configValueString converts a value of the configuration file into the string form of the flags.
+// Lists are converted into comma separated lists.
+func configValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			items = append(items, configValueString(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// watchConfig applies the configuration file to the controller whenever it changes.
+// Invalid configurations are rejected and the last valid configuration remains in effect.
+func watchConfig(ctx context.Context, ctlr *controller.Controller, path string) {
+	last, _ := os.ReadFile(path)
+
+	wait.Until(func() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			klog.ErrorS(err, "error reading configuration file", "path", path)
+			return
+		}
+
+		if bytes.Equal(data, last) {
+			return
+		}
+		last = data
+
+		cfg, err := loadConfig(path, true)
+		if err == nil {
+			err = ctlr.UpdateConfig(cfg)
+		}
+		controller.RecordConfigReload(err)
+
+		if err != nil {
+			klog.ErrorS(err, "rejected configuration file, keeping the last valid configuration", "path", path)
+			return
+		}
+
+		klog.InfoS("reloaded configuration file", "path", path)
+	}, configReloadInterval, ctx.Done())
+}
+
+func stringInSlice(val string, vals []string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/StatCan/ingress-istio-controller/pkg/controller"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// The subcommand translating manifests into VirtualServices without a cluster.
+const convertCommand = "convert"
+
+// runConvert prints the VirtualServices and Ingress statuses the controller would produce
+// for the manifests of the files, or of stdin if there are none or the file is "-".
+// It returns the exit code of the subcommand, which is non-zero if references could not be resolved.
+func runConvert(identity *controller.Identity, paths []string) int {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	objects := []*unstructured.Unstructured{}
+	for _, path := range paths {
+		objs, err := readManifests(path)
+		if err != nil {
+			klog.ErrorS(err, "error reading manifests", "path", path)
+			return 2
+		}
+		objects = append(objects, objs...)
+	}
+
+	result, err := controller.Convert(identity, currentConfig(), deterministicNames, objects)
+	if err != nil {
+		klog.ErrorS(err, "error converting ingresses")
+		return 2
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	for _, vs := range result.VirtualServices {
+		if err := printManifest(out, vs); err != nil {
+			klog.ErrorS(err, "error printing virtual service")
+			return 2
+		}
+	}
+
+	for _, ingress := range result.Ingresses {
+		// Only the status of the Ingresses is changed by the controller
+		status := map[string]interface{}{
+			"apiVersion": ingress.APIVersion,
+			"kind":       ingress.Kind,
+			"metadata": map[string]interface{}{
+				"name":      ingress.Name,
+				"namespace": ingress.Namespace,
+			},
+			"status": ingress.Status,
+		}
+		if err := printManifest(out, status); err != nil {
+			klog.ErrorS(err, "error printing ingress status")
+			return 2
+		}
+	}
+
+	for _, problem := range result.Problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	if len(result.Problems) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// readManifests reads the YAML or JSON documents of the file, or of stdin if the path is "-".
+func readManifests(path string) ([]*unstructured.Unstructured, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	objects := []*unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Empty documents
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item.(*unstructured.Unstructured))
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
+
+// printManifest prints the object as a YAML document.
+func printManifest(w io.Writer, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "---\n%s", data)
+	return err
+}
@@ -3,44 +3,148 @@ package main
 import (
 	"context"
 	"flag"
-	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/StatCan/ingress-istio-controller/pkg/controller"
 	istio "istio.io/client-go/pkg/clientset/versioned"
 	istioinformers "istio.io/client-go/pkg/informers/externalversions"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	istionetworkinginformers "istio.io/client-go/pkg/informers/externalversions/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	kubeinformers "k8s.io/client-go/informers"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	networkinginformers "k8s.io/client-go/informers/networking/v1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
-	"k8s.io/client-go/transport"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 )
 
 var (
-	masterURL      string
-	kubeconfig     string
-	clusterDomain  string
-	defaultGateway string
-	scopedGateways bool
-	ingressClass   string
-	defaultWeight  int
-	lockName       string
-	lockNamespace  string
-	lockIdentity   string
+	masterURL              string
+	kubeconfig             string
+	clusterDomain          string
+	defaultGateway         string
+	scopedGateways         bool
+	publishStatusAddress   string
+	publishService         string
+	ingressClass           string
+	defaultWeight          int
+	defaultExportTo        string
+	deterministicNames     bool
+	disableRateLimiting    bool
+	outputMode             string
+	rootVirtualService     string
+	istioNetworkingVersion string
+	lockName               string
+	lockNamespace          string
+	lockIdentity           string
+	lockType               string
+	lockContext            string
+	leaderElect            bool
+	leaseDuration          time.Duration
+	renewDeadline          time.Duration
+	retryPeriod            time.Duration
+	listenAddress          string
+	logFormat              string
+	configPath             string
+	watchNamespaces        string
+	gatewayNamespaces      string
+	namespaceSelector      string
+	controllerName         string
+	annotationPrefix       string
+	managedBy              string
+	workers                int
+	resyncQPS              float64
+	resyncPeriod           time.Duration
+	queueBaseDelay         time.Duration
+	queueMaxDelay          time.Duration
+	queueQPS               float64
+	queueBurst             int
+	dryRun                 bool
+	webhookListenAddress   string
+	debugListenAddress     string
+	webhookCertFile        string
+	webhookKeyFile         string
+	webhookAllowedGateways string
 )
 
 func main() {
 	klog.InitFlags(nil)
-	flag.Parse()
+
+	// The convert subcommand accepts the same flags as the controller
+	convert := len(os.Args) > 1 && os.Args[1] == convertCommand
+	if convert {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		commandLineFlags[f.Name] = true
+	})
+
+	if configPath != "" {
+		_, err := loadConfig(configPath, false)
+		if err != nil {
+			klog.Fatalf("error loading configuration file: %v", err)
+		}
+		controller.RecordConfigReload(nil)
+	}
+
+	switch logFormat {
+	case controller.JSONLogFormat:
+		klog.SetLogger(controller.NewJSONLogger(os.Stderr))
+	case controller.TextLogFormat:
+	default:
+		klog.Fatalf("invalid log format %q", logFormat)
+	}
+
+	identity, err := controller.NewIdentity(controllerName, annotationPrefix, managedBy)
+	if err != nil {
+		klog.Fatalf("invalid controller identity: %v", err)
+	}
+
+	if convert {
+		os.Exit(runConvert(identity, flag.Args()))
+	}
+
+	if workers < 1 {
+		klog.Fatalf("invalid number of workers %d: at least one worker is required", workers)
+	}
+
+	if resyncQPS <= 0 {
+		klog.Fatalf("invalid resync qps %v: expected a positive value", resyncQPS)
+	}
+
+	allowedGateways := []string{}
+	if webhookListenAddress != "" {
+		if webhookCertFile == "" || webhookKeyFile == "" {
+			klog.Fatalf("the webhook certificate and key files are required to serve the webhook")
+		}
+
+		for _, gateway := range strings.Split(webhookAllowedGateways, ",") {
+			gateway = strings.TrimSpace(gateway)
+			if gateway == "" {
+				continue
+			}
+			if strings.Count(gateway, "/") != 1 {
+				klog.Fatalf("invalid webhook allowed gateway %q: expected <namespace>/<name> or <namespace>/*", gateway)
+			}
+			allowedGateways = append(allowedGateways, gateway)
+		}
+	}
 
 	cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
 	if err != nil {
@@ -57,22 +161,175 @@ func main() {
 		klog.Fatalf("error building istio client: %v", err)
 	}
 
-	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeclient, time.Second*30)
-	istioInformerFactory := istioinformers.NewSharedInformerFactory(istioclient, time.Second*30)
+	dynamicclient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("error building dynamic client: %v", err)
+	}
+
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeclient, resyncPeriod)
+	istioInformerFactory := istioinformers.NewSharedInformerFactory(istioclient, resyncPeriod)
+	dynamicInformerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicclient, resyncPeriod)
+
+	if istioNetworkingVersion == "auto" {
+		istioNetworkingVersion, err = controller.DetectIstioNetworkingVersion(kubeclient.Discovery())
+		if err != nil {
+			klog.Fatalf("error detecting istio networking version: %v", err)
+		}
+		klog.Infof("using istio networking version %q", istioNetworkingVersion)
+	} else if !controller.ValidIstioNetworkingVersion(istioNetworkingVersion) {
+		klog.Fatalf("unknown istio networking version %q", istioNetworkingVersion)
+	}
+	istioNetworkingInformers := controller.NewIstioNetworkingInformers(istioNetworkingVersion, istioInformerFactory, dynamicInformerFactory)
+
+	// The Ingresses, Services and VirtualServices are watched in the selected namespaces only,
+	// using an informer factory per namespace. The informers are only requested from the factories
+	// which watch them, so that the cluster-wide factories do not watch these resources.
+	var ingressesInformer networkinginformers.IngressInformer
+	var servicesInformer corev1informers.ServiceInformer
+	var gatewayServicesInformer corev1informers.ServiceInformer
+	var virtualServicesInformer istionetworkinginformers.VirtualServiceInformer
+	namespacedFactories := []informerFactory{}
+	kubeFactories := map[string]kubeinformers.SharedInformerFactory{}
+	namespacedKubeFactory := func(namespace string) kubeinformers.SharedInformerFactory {
+		if _, ok := kubeFactories[namespace]; !ok {
+			kubeFactories[namespace] = kubeinformers.NewSharedInformerFactoryWithOptions(kubeclient, resyncPeriod, kubeinformers.WithNamespace(namespace))
+			namespacedFactories = append(namespacedFactories, kubeFactories[namespace])
+		}
+		return kubeFactories[namespace]
+	}
+	if watchNamespaces != "" {
+		ingressesInformers := map[string]networkinginformers.IngressInformer{}
+		servicesInformers := map[string]corev1informers.ServiceInformer{}
+		virtualServicesInformers := map[string]istionetworkinginformers.VirtualServiceInformer{}
+
+		for _, namespace := range strings.Split(watchNamespaces, ",") {
+			namespace = strings.TrimSpace(namespace)
+			if namespace == "" {
+				continue
+			}
+
+			kubeFactory := namespacedKubeFactory(namespace)
+			istioFactory := istioinformers.NewSharedInformerFactoryWithOptions(istioclient, resyncPeriod, istioinformers.WithNamespace(namespace))
+			dynamicFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicclient, resyncPeriod, namespace, nil)
+
+			ingressesInformers[namespace] = kubeFactory.Networking().V1().Ingresses()
+			servicesInformers[namespace] = kubeFactory.Core().V1().Services()
+			virtualServicesInformers[namespace] = controller.NewIstioNetworkingInformers(istioNetworkingVersion, istioFactory, dynamicFactory).VirtualServices()
+			namespacedFactories = append(namespacedFactories, istioFactory, dynamicFactory)
+		}
+
+		if len(ingressesInformers) == 0 {
+			klog.Fatalf("invalid watch namespaces %q", watchNamespaces)
+		}
+		klog.Infof("watching namespaces %q", watchNamespaces)
+
+		ingressesInformer = controller.NewMultiNamespaceIngressInformer(ingressesInformers)
+		servicesInformer = controller.NewMultiNamespaceServiceInformer(servicesInformers)
+		virtualServicesInformer = controller.NewMultiNamespaceVirtualServiceInformer(virtualServicesInformers)
+	} else {
+		ingressesInformer = kubeInformerFactory.Networking().V1().Ingresses()
+		servicesInformer = kubeInformerFactory.Core().V1().Services()
+		virtualServicesInformer = istioNetworkingInformers.VirtualServices()
+	}
+
+	// With the watched namespaces, the gateway Services are looked up in the namespaces
+	// of the default gateway and of the publish Service unless the gateway namespaces are set,
+	// so that the Services are not watched in all namespaces
+	if watchNamespaces != "" && gatewayNamespaces == "" {
+		namespaces := []string{}
+		for _, name := range []string{defaultGateway, publishService} {
+			if parts := strings.SplitN(name, "/", 2); len(parts) == 2 && (len(namespaces) == 0 || namespaces[0] != parts[0]) {
+				namespaces = append(namespaces, parts[0])
+			}
+		}
+
+		if len(namespaces) == 0 {
+			klog.Fatalf("the gateway namespaces are required with the watch namespaces when the default gateway %q has no namespace", defaultGateway)
+		}
+		gatewayNamespaces = strings.Join(namespaces, ",")
+		klog.Infof("looking up the gateway services in the namespaces %q", gatewayNamespaces)
+	}
 
-	ctlr := controller.NewController(
-		kubeclient,
-		istioclient,
-		clusterDomain,
-		defaultGateway,
-		scopedGateways,
-		ingressClass,
-		defaultWeight,
-		kubeInformerFactory.Networking().V1().Ingresses(),
-		kubeInformerFactory.Networking().V1().IngressClasses(),
-		kubeInformerFactory.Core().V1().Services(),
-		istioInformerFactory.Networking().V1beta1().VirtualServices(),
-		istioInformerFactory.Networking().V1beta1().Gateways())
+	// The gateway Services are looked up outside of the watched namespaces, whose Ingresses
+	// would otherwise be handled, in the gateway namespaces or else in all namespaces
+	switch {
+	case gatewayNamespaces != "":
+		gatewayServicesInformers := map[string]corev1informers.ServiceInformer{}
+		for _, namespace := range strings.Split(gatewayNamespaces, ",") {
+			namespace = strings.TrimSpace(namespace)
+			if namespace == "" {
+				continue
+			}
+			gatewayServicesInformers[namespace] = namespacedKubeFactory(namespace).Core().V1().Services()
+		}
+
+		if len(gatewayServicesInformers) == 0 {
+			klog.Fatalf("invalid gateway namespaces %q", gatewayNamespaces)
+		}
+		gatewayServicesInformer = controller.NewMultiNamespaceServiceInformer(gatewayServicesInformers)
+	default:
+		gatewayServicesInformer = servicesInformer
+	}
+
+	// Namespaces are only watched to match them against the namespace selector
+	var selector labels.Selector
+	var namespacesInformer corev1informers.NamespaceInformer
+	if namespaceSelector != "" {
+		selector, err = labels.Parse(namespaceSelector)
+		if err != nil {
+			klog.Fatalf("invalid namespace selector %q: %v", namespaceSelector, err)
+		}
+		namespacesInformer = kubeInformerFactory.Core().V1().Namespaces()
+	}
+
+	// The Gateway API resources are only watched in the httproute output mode,
+	// as their CRDs may not be installed in the cluster.
+	var httpRoutesInformer, gatewayAPIGatewaysInformer kubeinformers.GenericInformer
+	switch outputMode {
+	case controller.VirtualServiceOutputMode:
+	case controller.DelegateOutputMode:
+		if len(strings.Split(rootVirtualService, "/")) != 2 {
+			klog.Fatalf("the root virtual service is required in the delegate output mode in the <namespace>/<name> format: %q", rootVirtualService)
+		}
+	case controller.HTTPRouteOutputMode:
+		if dryRun {
+			klog.Fatalf("the dry-run mode is not supported in the httproute output mode")
+		}
+		httpRoutesInformer = dynamicInformerFactory.ForResource(controller.HTTPRouteResource)
+		gatewayAPIGatewaysInformer = dynamicInformerFactory.ForResource(controller.GatewayAPIGatewayResource)
+	default:
+		klog.Fatalf("unknown output mode %q", outputMode)
+	}
+
+	ctlr := controller.NewController(controller.Options{
+		KubeClientset:                 kubeclient,
+		IstioClientset:                istioclient,
+		DynamicClientset:              dynamicclient,
+		Identity:                      identity,
+		Config:                        currentConfig(),
+		DeterministicNames:            deterministicNames,
+		DryRun:                        dryRun,
+		OutputMode:                    outputMode,
+		RootVirtualService:            rootVirtualService,
+		IstioNetworkingVersion:        istioNetworkingVersion,
+		RateLimiter:                   controller.NewRateLimiter(queueBaseDelay, queueMaxDelay, queueQPS, queueBurst),
+		ResyncQPS:                     resyncQPS,
+		IngressesInformer:             ingressesInformer,
+		IngressClassesInformer:        kubeInformerFactory.Networking().V1().IngressClasses(),
+		ServicesInformer:              servicesInformer,
+		GatewayServicesInformer:       gatewayServicesInformer,
+		NodesInformer:                 kubeInformerFactory.Core().V1().Nodes(),
+		VirtualServicesInformer:       virtualServicesInformer,
+		GatewaysInformer:              istioNetworkingInformers.Gateways(),
+		AuthorizationPoliciesInformer: istioInformerFactory.Security().V1beta1().AuthorizationPolicies(),
+		EnvoyFiltersInformer:          istioInformerFactory.Networking().V1alpha3().EnvoyFilters(),
+		ServiceEntriesInformer:        istioNetworkingInformers.ServiceEntries(),
+		DestinationRulesInformer:      istioNetworkingInformers.DestinationRules(),
+		HTTPRoutesInformer:            httpRoutesInformer,
+		GatewayAPIGatewaysInformer:    gatewayAPIGatewaysInformer,
+		NamespaceSelector:             selector,
+		NamespacesInformer:            namespacesInformer,
+	})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -83,50 +340,134 @@ func main() {
 		<-wait
 		klog.Info("received signal, shutting down")
 		cancel()
+
+		<-wait
+		klog.Info("received second signal, exiting without draining")
+		os.Exit(1)
 	}()
 
+	if configPath != "" {
+		go watchConfig(ctx, ctlr, configPath)
+	}
+
 	kubeInformerFactory.Start(ctx.Done())
 	istioInformerFactory.Start(ctx.Done())
+	dynamicInformerFactory.Start(ctx.Done())
+	for _, factory := range namespacedFactories {
+		factory.Start(ctx.Done())
+	}
+
+	go serveHTTP(ctlr, ctx)
+	if debugListenAddress != "" {
+		go serveDebug(ctlr, ctx)
+	}
+	if webhookListenAddress != "" {
+		go serveWebhook(ctlr, allowedGateways, ctx)
+	}
 
-	runWithLeaderElection(ctlr, cfg, kubeclient, ctx)
+	if leaderElect {
+		runWithLeaderElection(ctlr, cfg, ctx)
+	} else {
+		runWithoutLeaderElection(ctlr, ctx)
+	}
 }
 
-func runWithLeaderElection(ctlr *controller.Controller, cfg *rest.Config, kubeclient *kubernetes.Clientset, ctx context.Context) {
+// informerFactory is implemented by the informer factories of all the clients.
+type informerFactory interface {
+	Start(stopCh <-chan struct{})
+}
 
+func runWithLeaderElection(ctlr *controller.Controller, cfg *rest.Config, ctx context.Context) {
 	// Acquire a lock
 	// Identity used to distinguish between multiple cloud controller manager instances
 	klog.Infof("leader identity id: %s", lockIdentity)
 
-	var lock resourcelock.Interface
+	// The lock may live in another cluster, selected by its kubeconfig context
+	if lockContext != "" {
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = kubeconfig
 
-	lock = &resourcelock.LeaseLock{
-		LeaseMeta: metav1.ObjectMeta{
-			Name:      lockName,
-			Namespace: lockNamespace,
-		},
-		Client: kubeclient.CoordinationV1(),
-		LockConfig: resourcelock.ResourceLockConfig{
-			Identity: lockIdentity,
-		},
+		var err error
+		cfg, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: lockContext}).ClientConfig()
+		if err != nil {
+			klog.Fatalf("error building kubeconfig of the lock context %q: %v", lockContext, err)
+		}
+	}
+
+	lockclient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("error building lock clientset: %v", err)
+	}
+
+	if lockType != resourcelock.LeasesResourceLock && lockType != resourcelock.ConfigMapsResourceLock {
+		klog.Fatalf("invalid lock type %q", lockType)
+	}
+
+	lock, err := resourcelock.New(lockType, lockNamespace, lockName, lockclient.CoreV1(), lockclient.CoordinationV1(), resourcelock.ResourceLockConfig{
+		Identity: lockIdentity,
+	})
+	if err != nil {
+		klog.Fatalf("error creating leader lock: %v", err)
 	}
 
-	cfg.Wrap(transport.ContextCanceller(ctx, fmt.Errorf("the leader is shutting down")))
+	// The lease is only released once the in-flight work is drained,
+	// so the leader election runs until the controller has stopped.
+	leaderCtx, stopLeading := context.WithCancel(context.Background())
+	defer stopLeading()
 
-	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+	var leadingLock sync.Mutex
+	leading := false
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+
+		leadingLock.Lock()
+		wasLeading := leading
+		leadingLock.Unlock()
+
+		if wasLeading {
+			<-stopped
+		}
+		stopLeading()
+	}()
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
 		Lock:            lock,
 		ReleaseOnCancel: true,
-		LeaseDuration:   15 * time.Second,
-		RenewDeadline:   10 * time.Second,
-		RetryPeriod:     2 * time.Second,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
 		Callbacks: leaderelection.LeaderCallbacks{
-			OnStartedLeading: func(ctx context.Context) {
-				if err := ctlr.Run(2, ctx); err != nil {
+			OnStartedLeading: func(leaseCtx context.Context) {
+				leadingLock.Lock()
+				if ctx.Err() != nil {
+					leadingLock.Unlock()
+					return
+				}
+				leading = true
+				leadingLock.Unlock()
+				defer close(stopped)
+
+				// The controller stops when the lease is lost or on shutdown
+				runCtx, cancel := context.WithCancel(leaseCtx)
+				defer cancel()
+				go func() {
+					select {
+					case <-ctx.Done():
+						cancel()
+					case <-runCtx.Done():
+					}
+				}()
+
+				controller.SetLeader(true)
+				if err := ctlr.Run(workers, runCtx); err != nil {
 					if err != context.Canceled {
 						klog.Errorf("error running controller: %v", err)
 					}
 				}
 			},
 			OnStoppedLeading: func() {
+				controller.SetLeader(false)
 				klog.Info("stopped leading")
 			},
 			OnNewLeader: func(identity string) {
@@ -139,18 +480,131 @@ func runWithLeaderElection(ctlr *controller.Controller, cfg *rest.Config, kubecl
 			},
 		},
 	})
+	if err != nil {
+		klog.Fatalf("invalid leader election configuration: %v", err)
+	}
+
+	elector.Run(leaderCtx)
+}
+
+// runWithoutLeaderElection runs the controller until the context is cancelled,
+// without acquiring the leader lock.
+func runWithoutLeaderElection(ctlr *controller.Controller, ctx context.Context) {
+	klog.Info("leader election disabled")
+
+	controller.SetLeader(true)
+	defer controller.SetLeader(false)
+
+	if err := ctlr.Run(workers, ctx); err != nil {
+		klog.Errorf("error running controller: %v", err)
+	}
+}
+
+// serveHTTP serves the metrics and health endpoints of the controller until the context is cancelled.
+func serveHTTP(ctlr *controller.Controller, ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", controller.MetricsHandler())
+	mux.Handle("/healthz", ctlr.HealthzHandler())
+	mux.Handle("/readyz", ctlr.ReadyzHandler())
+	mux.Handle("/leader", controller.LeaderHandler())
+
+	server := &http.Server{Addr: listenAddress, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	klog.Infof("listening on %s", listenAddress)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		klog.Fatalf("error serving http: %v", err)
+	}
+}
+
+// serveDebug serves the debug endpoints of the controller until the context is cancelled.
+// They are served separately from the metrics and health endpoints, as they expose the translated
+// configuration and change the verbosity of the logs without authentication.
+func serveDebug(ctlr *controller.Controller, ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/ingresses/", ctlr.DebugIngressHandler())
+	mux.Handle("/debug/dry-run", ctlr.DryRunHandler())
+	mux.Handle("/debug/verbosity", controller.VerbosityHandler(flag.Lookup("v").Value))
+
+	server := &http.Server{Addr: debugListenAddress, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	klog.Infof("serving the debug endpoints on %s", debugListenAddress)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		klog.Fatalf("error serving debug endpoints: %v", err)
+	}
+}
+
+// serveWebhook serves the validating admission webhook over TLS until the context is cancelled.
+// The webhook is served by all the replicas, whether or not they hold the leader lock.
+func serveWebhook(ctlr *controller.Controller, allowedGateways []string, ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle("/validate-ingress", ctlr.ValidatingWebhookHandler(allowedGateways))
+
+	server := &http.Server{Addr: webhookListenAddress, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	klog.Infof("serving the validating webhook on %s", webhookListenAddress)
+	if err := server.ListenAndServeTLS(webhookCertFile, webhookKeyFile); err != nil && err != http.ErrServerClosed {
+		klog.Fatalf("error serving webhook: %v", err)
+	}
 }
 
 func init() {
+	flag.StringVar(&configPath, "config", "", "Path to a YAML configuration file setting the arguments by name, and the annotation defaults under \"annotation-defaults\". Changes to the file are applied at runtime. Arguments set on the command line take precedence.")
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&clusterDomain, "cluster-domain", "cluster.local", "The cluster domain.")
 	flag.StringVar(&defaultGateway, "default-gateway", "istio-system/istio-autogenerated-k8s-ingress", "The default Istio gateway used when no existing VirtualService is located matching the host.")
 	flag.BoolVar(&scopedGateways, "scoped-gateways", false, "Gateways are scoped to the same namespace they exist within. This will limit the Service search for Load Balancer status. In istiod, this is controlled via the PILOT_SCOPE_GATEWAY_TO_NAMESPACE environment variable.")
+	flag.StringVar(&publishStatusAddress, "publish-status-address", "", "Comma seperated list of IP addresses or hostnames published in the status of the Ingresses, instead of the addresses of the gateway Services.")
+	flag.StringVar(&publishService, "publish-service", "", "The Service, in the <namespace>/<name> format, whose addresses are published in the status of the Ingresses, instead of the addresses of the gateway Services.")
 	flag.StringVar(&ingressClass, "ingress-class", "", "The ingress class annotation to monitor (empty string to skip checking annotation)")
 	flag.IntVar(&defaultWeight, "virtual-service-weight", 100, "The weight of the Virtual Service destination.")
+	flag.BoolVar(&deterministicNames, "deterministic-names", false, "Name the generated VirtualServices after their Ingress instead of generating random names. Existing VirtualServices are renamed on startup.")
+	flag.StringVar(&defaultExportTo, "virtual-service-export-to", "", "Comma seperated list of the namespaces to which the generated VirtualServices are exported (empty string to export to all namespaces).")
+	flag.BoolVar(&disableRateLimiting, "disable-rate-limiting", false, "Disable the generation of EnvoyFilters for the rate limit annotations on Ingresses.")
+	flag.StringVar(&outputMode, "output-mode", controller.VirtualServiceOutputMode, "The routing resources generated for Ingresses: \"virtualservice\" for Istio VirtualServices, \"delegate\" for Istio VirtualServices delegated from the root VirtualService or \"httproute\" for Gateway API HTTPRoutes.")
+	flag.StringVar(&rootVirtualService, "root-virtual-service", "", "The root VirtualService, in the <namespace>/<name> format, to which the VirtualServices of the Ingresses are delegated in the \"delegate\" output mode.")
+	flag.StringVar(&istioNetworkingVersion, "istio-networking-version", controller.IstioNetworkingV1beta1, "The version of the Istio networking API used for VirtualServices, Gateways, ServiceEntries and DestinationRules: \"v1alpha3\", \"v1beta1\", \"v1\" or \"auto\" to use the most recent version served by the cluster. Versions other than v1beta1 are watched as unstructured objects, which are converted when read.")
 	flag.StringVar(&lockName, "lock-name", getEnvVarOrDefault("LOCK_NAME", "ingress-istio-controller"), "The name of the leader lock.")
 	flag.StringVar(&lockNamespace, "lock-namespace", getEnvVarOrDefault("LOCK_NAMESPACE", "ingress-istio-controller-system"), "The namespace where the leader lock resides.")
+	flag.StringVar(&listenAddress, "listen-address", ":8080", "The address on which the metrics and health endpoints are served.")
+	flag.StringVar(&debugListenAddress, "debug-listen-address", "", "The address on which the unauthenticated debug endpoints are served, such as localhost:8081 (empty string to disable them).")
+	flag.StringVar(&logFormat, "log-format", controller.TextLogFormat, "The format of the logs: \"text\" or \"json\".")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "", "Comma seperated list of the namespaces in which the Ingresses, Services and VirtualServices are watched (empty string to watch all namespaces). The Gateways, Nodes, IngressClasses, AuthorizationPolicies, EnvoyFilters, ServiceEntries and DestinationRules are still watched in all namespaces.")
+	flag.StringVar(&gatewayNamespaces, "gateway-namespaces", "", "Comma seperated list of the namespaces in which the gateway Services and the --publish-service are looked up (empty string for the namespaces of the --default-gateway and --publish-service with --watch-namespaces, or else all namespaces). The Ingresses of these namespaces are only handled if they are watched.")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "", "Label selector of the namespaces whose Ingresses are handled (empty string to handle the Ingresses of all namespaces).")
+	flag.StringVar(&controllerName, "controller-name", controller.DefaultControllerName, "The controller value of the IngressClasses handled by the controller. Distinct values allow several instances of the controller to run in the same cluster.")
+	flag.StringVar(&annotationPrefix, "annotation-prefix", controller.DefaultAnnotationPrefix, "The prefix of the annotations read by the controller and of the labels it sets.")
+	flag.StringVar(&managedBy, "managed-by", controller.DefaultManagedBy, "The value of the app.kubernetes.io/managed-by label of the generated resources. The controller only changes the resources carrying its value.")
+	flag.BoolVar(&leaderElect, "leader-elect", true, "Acquire the leader lock before running the controller. Disable to run a single replica, such as during local development.")
+	flag.DurationVar(&leaseDuration, "leader-elect-lease-duration", 15*time.Second, "The duration for which the other replicas wait before taking over the leader lock when it is not renewed.")
+	flag.DurationVar(&renewDeadline, "leader-elect-renew-deadline", 10*time.Second, "The duration during which the leader retries renewing the leader lock before giving it up.")
+	flag.DurationVar(&retryPeriod, "leader-elect-retry-period", 2*time.Second, "The duration between the attempts to acquire and renew the leader lock.")
+	flag.StringVar(&lockType, "lock-type", resourcelock.LeasesResourceLock, "The resource holding the leader lock: \"leases\" or \"configmaps\".")
+	flag.StringVar(&lockContext, "lock-context", "", "The kubeconfig context of the cluster holding the leader lock (empty string for the cluster of the controller).")
+	flag.IntVar(&workers, "workers", 2, "The number of workers reconciling Ingresses concurrently.")
+	flag.Float64Var(&resyncQPS, "resync-qps", 10, "The maximum number of unchanged Ingresses reconciled per second on resync.")
+	flag.DurationVar(&resyncPeriod, "resync-period", 30*time.Second, "The period at which the informers resync, reconciling all the Ingresses.")
+	flag.DurationVar(&queueBaseDelay, "queue-base-delay", 5*time.Millisecond, "The delay before the first retry of an Ingress which failed to reconcile, doubled on each failure.")
+	flag.DurationVar(&queueMaxDelay, "queue-max-delay", 1000*time.Second, "The maximum delay between the retries of an Ingress which failed to reconcile.")
+	flag.Float64Var(&queueQPS, "queue-qps", 10, "The maximum number of retries of failed Ingresses per second.")
+	flag.IntVar(&queueBurst, "queue-burst", 100, "The maximum burst of retries of failed Ingresses.")
+	flag.BoolVar(&dryRun, "dry-run", false, "Log and expose the changes to the VirtualServices and the status of the Ingresses instead of applying them. The other generated resources are left unchanged.")
+	flag.StringVar(&webhookListenAddress, "webhook-listen-address", "", "The address on which the validating admission webhook for Ingresses is served over TLS, under the /validate-ingress path (empty string to disable the webhook).")
+	flag.StringVar(&webhookCertFile, "webhook-cert-file", "", "The path to the TLS certificate of the validating webhook.")
+	flag.StringVar(&webhookKeyFile, "webhook-key-file", "", "The path to the TLS private key of the validating webhook.")
+	flag.StringVar(&webhookAllowedGateways, "webhook-allowed-gateways", "", "Comma seperated list of the gateways, in the <namespace>/<name> or <namespace>/* format, which Ingresses may request through the gateways annotation (empty string to allow all gateways).")
 	flag.StringVar(&lockIdentity, "lock-identity", getEnvVarOrDefault("LOCK_IDENTITY", createIdentity()), "The unique identity of the replica. (Pod name is best)")
 }
 
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// Name of an existing VirtualService in the namespace of the Ingress to take ownership of,
+	// followed by the confirm suffix once the changes reported by the dry-run were reviewed
+	AdoptVirtualServiceAnnotation = "ingress.statcan.gc.ca/adopt-virtualservice"
+)
+
+// Suffix of the adopt annotation confirming the adoption, such as "web:confirm".
+const adoptConfirmSuffix = ":confirm"
+
+// getAdoptVirtualServiceName returns the name of the VirtualService to adopt set by the adopt
+// annotation of the Ingress, and whether its adoption is confirmed.
+func getAdoptVirtualServiceName(id *Identity, ingress *networkingv1.Ingress) (string, bool) {
+	name := ingress.Annotations[id.key(AdoptVirtualServiceAnnotation)]
+	if strings.HasSuffix(name, adoptConfirmSuffix) {
+		return strings.TrimSuffix(name, adoptConfirmSuffix), true
+	}
+
+	return name, false
+}
+
+// findVirtualServiceToAdopt returns the VirtualService named by the adopt annotation of the Ingress,
+// if it exists and is not yet controlled by the Ingress.
+func (c *Controller) findVirtualServiceToAdopt(ingress *networkingv1.Ingress) (*istionetworkingv1beta1.VirtualService, error) {
+	name, _ := getAdoptVirtualServiceName(c.identity, ingress)
+	if name == "" {
+		return nil, nil
+	}
+
+	vs, err := c.virtualServicesListers.VirtualServices(ingress.Namespace).Get(name)
+	if errors.IsNotFound(err) {
+		klog.InfoS("virtualservice to adopt does not exist", c.logValues(ingress.Namespace, ingress.Name, "adopt", name)...)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if metav1.IsControlledBy(vs, ingress) {
+		return nil, nil
+	}
+
+	if ownerRef := metav1.GetControllerOf(vs); ownerRef != nil {
+		return nil, fmt.Errorf("virtualservice \"%s/%s\" to adopt is already controlled by %s %q", vs.Namespace, vs.Name, ownerRef.Kind, ownerRef.Name)
+	}
+
+	if managedBy, ok := vs.Labels[managedByLabel]; ok && managedBy != c.identity.managedBy {
+		return nil, fmt.Errorf("virtualservice \"%s/%s\" to adopt is managed by %q", vs.Namespace, vs.Name, managedBy)
+	}
+
+	return vs, nil
+}
+
+// adoptVirtualService takes ownership of an existing VirtualService for the Ingress, replacing its spec
+// with the generated spec. Until the adoption is confirmed, it is submitted as a dry-run, the changes
+// it would make are reported in an Event on the Ingress and no VirtualService is returned.
+func (c *Controller) adoptVirtualService(ingress *networkingv1.Ingress, vs *istionetworkingv1beta1.VirtualService, nvs *istionetworkingv1beta1.VirtualService, confirmed bool) (*istionetworkingv1beta1.VirtualService, error) {
+	ctx := context.Background()
+
+	key, err := cache.MetaNamespaceKeyFunc(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	// The spec is replaced through an update rather than applied, as the fields of the previous
+	// managers, such as tcp routes, would otherwise be kept alongside the generated ones.
+	avs := vs.DeepCopy()
+	avs.Labels, avs.Annotations = generateObjectMetadata(c.identity, ingress, vs.Labels, vs.Annotations)
+	avs.OwnerReferences = append(avs.OwnerReferences, ingressOwnerReference(ingress))
+	avs.Spec = nvs.DeepCopy().Spec
+
+	opts := metav1.UpdateOptions{FieldManager: c.identity.managedBy}
+	if !confirmed {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	updated, err := c.istioNetworking.VirtualServices(vs.Namespace).Update(ctx, avs, opts)
+	if err != nil {
+		c.recorder.Eventf(ingress, corev1.EventTypeWarning, ReasonAdoptVirtualServiceFailed, "Adoption of virtualservice %q failed: %v", vs.Name, err)
+		return nil, err
+	}
+
+	if !confirmed {
+		// Reported again only when the changes differ from the previous reconcile
+		message := fmt.Sprintf("Adopting virtualservice %q once the %s annotation is set to %q: %s", vs.Name, c.identity.key(AdoptVirtualServiceAnnotation), vs.Name+adoptConfirmSuffix, describeVirtualServiceChanges(vs, updated))
+		for _, message := range c.reportedWarnings.update(key, ReasonAdoptVirtualServiceDryRun, []string{message}) {
+			c.recorder.Event(ingress, corev1.EventTypeNormal, ReasonAdoptVirtualServiceDryRun, message)
+		}
+		return nil, nil
+	}
+	c.reportedWarnings.update(key, ReasonAdoptVirtualServiceDryRun, nil)
+
+	// The fields of the update are handed to the apply manager, so that they are removed when no longer generated
+	klog.InfoS("adopting virtual service", c.logValues(ingress.Namespace, ingress.Name, "virtualService", vs.Name)...)
+	return c.istioNetworking.upgradeVirtualServiceManagedFields(ctx, updated)
+}
+
+// describeVirtualServiceChanges summarizes the changes from the current to the desired VirtualService.
+func describeVirtualServiceChanges(current, desired *istionetworkingv1beta1.VirtualService) string {
+	changes := []string{"controller reference added"}
+
+	if !stringArrayEquals(current.Spec.Hosts, desired.Spec.Hosts) {
+		changes = append(changes, fmt.Sprintf("hosts %v -> %v", current.Spec.Hosts, desired.Spec.Hosts))
+	}
+
+	if !stringArrayEquals(current.Spec.Gateways, desired.Spec.Gateways) {
+		changes = append(changes, fmt.Sprintf("gateways %v -> %v", current.Spec.Gateways, desired.Spec.Gateways))
+	}
+
+	if !reflect.DeepEqual(current.Spec.Http, desired.Spec.Http) {
+		changes = append(changes, fmt.Sprintf("http routes replaced (%d -> %d)", len(current.Spec.Http), len(desired.Spec.Http)))
+	}
+
+	if len(current.Spec.Tls) != len(desired.Spec.Tls) || len(current.Spec.Tcp) != len(desired.Spec.Tcp) {
+		changes = append(changes, fmt.Sprintf("tls routes (%d -> %d), tcp routes (%d -> %d)", len(current.Spec.Tls), len(desired.Spec.Tls), len(current.Spec.Tcp), len(desired.Spec.Tcp)))
+	}
+
+	if !stringArrayEquals(current.Spec.ExportTo, desired.Spec.ExportTo) {
+		changes = append(changes, fmt.Sprintf("exportTo %v -> %v", current.Spec.ExportTo, desired.Spec.ExportTo))
+	}
+
+	keys := make([]string, 0, len(desired.Labels))
+	for k := range desired.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if current.Labels[k] != desired.Labels[k] {
+			changes = append(changes, fmt.Sprintf("label %s=%s set", k, desired.Labels[k]))
+		}
+	}
+
+	return strings.Join(changes, ", ")
+}
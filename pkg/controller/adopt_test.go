@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestGetAdoptVirtualServiceName(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		want      string
+		confirmed bool
+	}{
+		{
+			name:  "not set",
+			value: "",
+			want:  "",
+		},
+		{
+			name:  "dry-run",
+			value: "web",
+			want:  "web",
+		},
+		{
+			name:      "confirmed",
+			value:     "web:confirm",
+			want:      "web",
+			confirmed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AdoptVirtualServiceAnnotation: test.value}}}
+
+			name, confirmed := getAdoptVirtualServiceName(DefaultIdentity(), ingress)
+			if name != test.want || confirmed != test.confirmed {
+				t.Errorf("expected (%q, %t), got (%q, %t)", test.want, test.confirmed, name, confirmed)
+			}
+		})
+	}
+}
+
+func TestAdoptVirtualService(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Number: 80}
+	ingress := testIngress("web", "a.example.com", "/", "web", http80, nil)
+	ingress.UID = "web-uid"
+
+	handwritten := &istionetworkingv1beta1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "handwritten", Namespace: "app", ResourceVersion: "1", Labels: map[string]string{"team": "web"}},
+		Spec: v1beta1.VirtualService{
+			Hosts:    []string{"a.example.com"},
+			ExportTo: []string{"."},
+			Http:     []*v1beta1.HTTPRoute{{Route: []*v1beta1.HTTPRouteDestination{{Destination: &v1beta1.Destination{Host: "legacy"}}}}},
+			Tcp:      []*v1beta1.TCPRoute{{Route: []*v1beta1.RouteDestination{{Destination: &v1beta1.Destination{Host: "db"}}}}},
+		},
+	}
+	generated := &istionetworkingv1beta1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "handwritten", Namespace: "app"},
+		Spec: v1beta1.VirtualService{
+			Hosts:    []string{"a.example.com"},
+			Gateways: []string{"istio-system/ingressgateway"},
+			Http:     []*v1beta1.HTTPRoute{{Route: []*v1beta1.HTTPRouteDestination{{Destination: &v1beta1.Destination{Host: "web.app.svc.cluster.local"}}}}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		confirmed bool
+		events    []string
+		adopted   bool
+	}{
+		{
+			name:   "pending confirmation",
+			events: []string{"Normal AdoptVirtualServiceDryRun Adopting virtualservice \"handwritten\" once the ingress.statcan.gc.ca/adopt-virtualservice annotation is set to \"handwritten:confirm\": controller reference added, gateways [] -> [istio-system/ingressgateway], http routes replaced (1 -> 1), tls routes (0 -> 0), tcp routes (1 -> 0), exportTo [.] -> [], label app.kubernetes.io/created-by=ingress-istio-controller set, label app.kubernetes.io/managed-by=ingress-istio-controller set"},
+		},
+		{
+			name:      "confirmed",
+			confirmed: true,
+			adopted:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestController(t, Config{}, ingress)
+			c.istioNetworking = &istioNetworking{version: IstioNetworkingV1beta1, istioclientset: istiofake.NewSimpleClientset(handwritten.DeepCopy()), fieldManager: DefaultManagedBy}
+
+			// Reconciled twice before the confirmation, the changes are only reported once
+			reconciles := 2
+			if test.confirmed {
+				reconciles = 1
+			}
+
+			var vs *istionetworkingv1beta1.VirtualService
+			for i := 0; i < reconciles; i++ {
+				var err error
+				vs, err = c.adoptVirtualService(ingress, handwritten, generated, test.confirmed)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			events := []string{}
+			recorder := c.recorder.(*record.FakeRecorder)
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if strings.Join(events, "\n") != strings.Join(test.events, "\n") {
+				t.Errorf("expected events %q, got %q", test.events, events)
+			}
+
+			if !test.adopted {
+				if vs != nil {
+					t.Errorf("expected no virtualservice until the adoption is confirmed")
+				}
+				return
+			}
+
+			if vs == nil {
+				t.Fatalf("expected the adopted virtualservice")
+			}
+			if !metav1.IsControlledBy(vs, ingress) {
+				t.Errorf("expected the virtualservice to be controlled by the ingress")
+			}
+			if len(vs.Spec.Tcp) > 0 || len(vs.Spec.ExportTo) > 0 {
+				t.Errorf("expected the fields of the previous manager to be removed, got %v", vs.Spec)
+			}
+			if vs.Labels["team"] != "web" || vs.Labels[managedByLabel] != DefaultManagedBy {
+				t.Errorf("unexpected labels %v", vs.Labels)
+			}
+		})
+	}
+}
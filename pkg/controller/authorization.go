@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/api/networking/v1beta1"
+	securityv1beta1 "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istiosecurityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// Name of the extension provider defined in the mesh config
+	AuthProviderAnnotation = "ingress.statcan.gc.ca/auth-provider"
+	// Comma seperated list of paths which are not sent to the extension provider
+	AuthExcludedPathsAnnotation = "ingress.statcan.gc.ca/auth-excluded-paths"
+)
+
+// handleAuthorizationPoliciesForIngress synchronizes the CUSTOM AuthorizationPolicies
+// applied to the gateway workloads for the Ingress.
+// If vs is nil, the Ingress is not handled and all of its policies are removed.
+func (c *Controller) handleAuthorizationPoliciesForIngress(ingress *networkingv1.Ingress, vs *istionetworkingv1beta1.VirtualService) error {
+	// Left unchanged in the dry-run mode
+	if c.dryRun {
+		return nil
+	}
+
+	desired := []*istiosecurityv1beta1.AuthorizationPolicy{}
+
+	if provider, ok := ingress.Annotations[c.identity.key(AuthProviderAnnotation)]; ok && vs != nil {
+		gateways, err := c.getGatewaysForVirtualService(vs)
+		if err != nil {
+			return err
+		}
+
+		desired, err = c.generateAuthorizationPolicies(ingress, gateways, provider)
+		if err != nil {
+			return err
+		}
+	}
+
+	existing, err := c.authorizationPoliciesLister.List(c.identity.ingressReferenceSelector(ingress.Namespace, ingress.Name))
+	if err != nil {
+		return err
+	}
+
+	return syncOwnedObjects(c, ingress.Namespace, ingress.Name, c.authorizationPolicies(), existing, desired)
+}
+
+// removeAuthorizationPoliciesForIngress removes the AuthorizationPolicies
+// generated for a deleted Ingress.
+func (c *Controller) removeAuthorizationPoliciesForIngress(namespace, name string) error {
+	policies, err := c.authorizationPoliciesLister.List(c.identity.ingressReferenceSelector(namespace, name))
+	if err != nil {
+		return err
+	}
+
+	return syncOwnedObjects(c, namespace, name, c.authorizationPolicies(), policies, nil)
+}
+
+// authorizationPolicies describes the AuthorizationPolicies generated for the Ingresses.
+func (c *Controller) authorizationPolicies() ownedKind[istiosecurityv1beta1.AuthorizationPolicy] {
+	return ownedKind[istiosecurityv1beta1.AuthorizationPolicy]{
+		name:   "authorization policy",
+		logKey: "authorizationPolicy",
+		client: func(namespace string) istioNetworkingClient[istiosecurityv1beta1.AuthorizationPolicy] {
+			return c.istioclientset.SecurityV1beta1().AuthorizationPolicies(namespace)
+		},
+		withSpec: func(current, desired *istiosecurityv1beta1.AuthorizationPolicy) *istiosecurityv1beta1.AuthorizationPolicy {
+			updated := current.DeepCopy()
+			updated.Spec = desired.Spec
+			return updated
+		},
+	}
+}
+
+// generateAuthorizationPolicies generates a CUSTOM AuthorizationPolicy for each gateway.
+// The policies are created in the namespaces of the gateway workloads,
+// so they cannot be owned by the Ingress and are tracked through labels instead.
+func (c *Controller) generateAuthorizationPolicies(ingress *networkingv1.Ingress, gateways []*istionetworkingv1beta1.Gateway, provider string) ([]*istiosecurityv1beta1.AuthorizationPolicy, error) {
+	if provider == "" {
+		return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on \"%s/%s\": provider name is empty", c.identity.key(AuthProviderAnnotation), ingress.Namespace, ingress.Name)
+	}
+
+	var excludedPaths []string
+	if val, ok := ingress.Annotations[c.identity.key(AuthExcludedPathsAnnotation)]; ok {
+		for _, path := range strings.Split(val, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				excludedPaths = append(excludedPaths, path)
+			}
+		}
+	}
+
+	policies := []*istiosecurityv1beta1.AuthorizationPolicy{}
+
+	for _, gateway := range gateways {
+		if len(gateway.Spec.Selector) == 0 {
+			klog.InfoS("gateway has no workload selector, skipping authorization policy", c.logValues(ingress.Namespace, ingress.Name, "gateway", klog.KObj(gateway))...)
+			continue
+		}
+
+		portsOnGateways := c.getNonHTTPPRedirectPortsOnGateways([]*istionetworkingv1beta1.Gateway{gateway})
+
+		rule := &securityv1beta1.Rule{}
+		for _, ingressRule := range ingress.Spec.Rules {
+			if ingressRule.HTTP == nil {
+				continue
+			}
+
+			operation := &securityv1beta1.Operation{
+				NotPaths: excludedPaths,
+			}
+
+			if ingressRule.Host != "" && ingressRule.Host != "*" {
+				operation.Hosts = createAuthorizationHosts(ingressRule.Host, portsOnGateways)
+			}
+
+			for _, path := range ingressRule.HTTP.Paths {
+				for _, p := range createAuthorizationPaths(path) {
+					if !stringInArray(p, operation.Paths) {
+						operation.Paths = append(operation.Paths, p)
+					}
+				}
+			}
+
+			rule.To = append(rule.To, &securityv1beta1.Rule_To{Operation: operation})
+		}
+
+		if len(rule.To) == 0 {
+			continue
+		}
+
+		namespaces, err := c.getWorkloadNamespacesForGateway(gateway)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, namespace := range namespaces {
+			policies = append(policies, &istiosecurityv1beta1.AuthorizationPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					// Gateways of the same name in other namespaces may select the same workloads
+					Name:        generatedName(ingress.Namespace, ingress.Name, gateway.Namespace, gateway.Name),
+					Namespace:   namespace,
+					Labels:      c.identity.ingressReferenceLabels(ingress.Namespace, ingress.Name),
+					Annotations: c.identity.ingressReferenceAnnotations(ingress.Name),
+				},
+				Spec: securityv1beta1.AuthorizationPolicy{
+					Selector: &typev1beta1.WorkloadSelector{
+						MatchLabels: gateway.Spec.Selector,
+					},
+					Action: securityv1beta1.AuthorizationPolicy_CUSTOM,
+					ActionDetail: &securityv1beta1.AuthorizationPolicy_Provider{
+						Provider: &securityv1beta1.AuthorizationPolicy_ExtensionProvider{
+							Name: provider,
+						},
+					},
+					Rules: []*securityv1beta1.Rule{rule},
+				},
+			})
+		}
+	}
+
+	return policies, nil
+}
+
+// getWorkloadNamespacesForGateway returns the namespaces of the workloads selected by the Gateway.
+// The namespaces of the Services for the Gateway are used, as an AuthorizationPolicy only applies
+// to workloads in its own namespace. Falls back to the namespace of the Gateway.
+func (c *Controller) getWorkloadNamespacesForGateway(gateway *istionetworkingv1beta1.Gateway) ([]string, error) {
+	services, err := c.getServicesForGateway(gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := []string{}
+	for _, service := range services {
+		if !stringInArray(service.Namespace, namespaces) {
+			namespaces = append(namespaces, service.Namespace)
+		}
+	}
+
+	if len(namespaces) == 0 {
+		namespaces = append(namespaces, gateway.Namespace)
+	}
+
+	return namespaces, nil
+}
+
+// Creates the hosts to match in an AuthorizationPolicy, including the variants
+// with the ports on which the host is advertised.
+func createAuthorizationHosts(host string, ports []uint32) []string {
+	hosts := []string{host}
+
+	for _, port := range ports {
+		hosts = append(hosts, fmt.Sprintf("%s:%d", host, port))
+	}
+
+	return hosts
+}
+
+// Converts an Ingress path into the paths matched by an AuthorizationPolicy,
+// which only supports exact, prefix ("/foo*") and suffix matches.
+func createAuthorizationPaths(path networkingv1.HTTPIngressPath) []string {
+	match := createStringMatch(path)
+	if match == nil {
+		return []string{"*"}
+	}
+
+	switch m := match.MatchType.(type) {
+	case *v1beta1.StringMatch_Exact:
+		return []string{m.Exact}
+	case *v1beta1.StringMatch_Prefix:
+		if strings.HasSuffix(m.Prefix, "/") && m.Prefix != "/" {
+			// Prefix paths also match the path without the trailing slash
+			return []string{strings.TrimSuffix(m.Prefix, "/"), m.Prefix + "*"}
+		}
+		return []string{m.Prefix + "*"}
+	}
+
+	return []string{"*"}
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// Name of the Secret in the namespace of the gateway workloads holding the
+	// server certificate and key of the hosts of the Ingress. It is the credential of the
+	// generated Gateway, so the CA used to verify client certificates is resolved by Istio
+	// from its ca.crt key or from the <name>-cacert Secret.
+	ClientCASecretAnnotation = "ingress.statcan.gc.ca/client-ca-secret"
+	// TLS mode used to verify client certificates (MUTUAL)
+	ClientVerificationAnnotation = "ingress.statcan.gc.ca/client-verification"
+)
+
+// handleClientCertificateGatewaysForIngress synchronizes the dedicated Gateways
+// requiring client certificates for the hosts of the Ingress.
+// The names of the Gateways to which the VirtualService should be attached are returned.
+// If the Ingress does not require client certificates, the given gateways are returned as is.
+func (c *Controller) handleClientCertificateGatewaysForIngress(ingress *networkingv1.Ingress, gatewayNames []string) ([]string, error) {
+	desired, gatewayNames, err := c.getClientCertificateGatewaysForIngress(ingress, gatewayNames)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := c.gatewaysListers.List(c.identity.ingressReferenceSelector(ingress.Namespace, ingress.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syncOwnedObjects(c, ingress.Namespace, ingress.Name, c.clientCertificateGateways(), existing, desired); err != nil {
+		return nil, err
+	}
+
+	return gatewayNames, nil
+}
+
+// removeClientCertificateGatewaysForIngress removes the Gateways
+// generated for a deleted or unhandled Ingress.
+func (c *Controller) removeClientCertificateGatewaysForIngress(namespace, name string) error {
+	gateways, err := c.gatewaysListers.List(c.identity.ingressReferenceSelector(namespace, name))
+	if err != nil {
+		return err
+	}
+
+	return syncOwnedObjects(c, namespace, name, c.clientCertificateGateways(), gateways, nil)
+}
+
+// clientCertificateGateways describes the Gateways requiring client certificates generated for the Ingresses.
+func (c *Controller) clientCertificateGateways() ownedKind[istionetworkingv1beta1.Gateway] {
+	return ownedKind[istionetworkingv1beta1.Gateway]{
+		name:   "client certificate gateway",
+		logKey: "gateway",
+		client: c.istioNetworking.Gateways,
+		withSpec: func(current, desired *istionetworkingv1beta1.Gateway) *istionetworkingv1beta1.Gateway {
+			updated := current.DeepCopy()
+			updated.Spec = desired.Spec
+			return updated
+		},
+	}
+}
+
+// getClientCertificateGatewaysForIngress returns the dedicated Gateways requiring client certificates
+// for the hosts of the Ingress, and the names of the Gateways to which the VirtualService should be attached.
+func (c *Controller) getClientCertificateGatewaysForIngress(ingress *networkingv1.Ingress, gatewayNames []string) ([]*istionetworkingv1beta1.Gateway, []string, error) {
+	desired := []*istionetworkingv1beta1.Gateway{}
+
+	if secret, ok := ingress.Annotations[c.identity.key(ClientCASecretAnnotation)]; ok {
+		baseGateways, err := c.getGatewaysByName(gatewayNames, ingress.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		desired, err = generateClientCertificateGateways(c.identity, ingress, baseGateways, secret)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Only replace the gateways if at least one of them could be located,
+		// to avoid attaching the VirtualService to the mesh.
+		if len(desired) > 0 {
+			gatewayNames = []string{}
+			for _, gateway := range desired {
+				gatewayNames = append(gatewayNames, fmt.Sprintf("%s/%s", gateway.Namespace, gateway.Name))
+			}
+		}
+	}
+
+	return desired, gatewayNames, nil
+}
+
+// generateClientCertificateGateways generates a Gateway in MUTUAL mode for each of the base gateways.
+// The generated Gateways select the same workloads as the base gateways, and live in their namespace
+// so that the credential is resolved from the namespace of the gateway workloads.
+func generateClientCertificateGateways(id *Identity, ingress *networkingv1.Ingress, baseGateways []*istionetworkingv1beta1.Gateway, secret string) ([]*istionetworkingv1beta1.Gateway, error) {
+	if secret == "" {
+		return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on \"%s/%s\": secret name is empty", id.key(ClientCASecretAnnotation), ingress.Namespace, ingress.Name)
+	}
+
+	mode := v1beta1.ServerTLSSettings_MUTUAL
+	if val, ok := ingress.Annotations[id.key(ClientVerificationAnnotation)]; ok {
+		switch strings.ToUpper(val) {
+		case "MUTUAL":
+			mode = v1beta1.ServerTLSSettings_MUTUAL
+		case "ISTIO_MUTUAL":
+			// The certificates of the mesh are not presented by clients outside of it
+			return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on \"%s/%s\": ISTIO_MUTUAL verifies the certificates of the mesh workloads, use MUTUAL", id.key(ClientVerificationAnnotation), ingress.Namespace, ingress.Name)
+		default:
+			return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on \"%s/%s\": %q", id.key(ClientVerificationAnnotation), ingress.Namespace, ingress.Name, val)
+		}
+	}
+
+	// The server of a rule without a host would require client certificates
+	// for all of the hosts on the port of the base gateway
+	hosts := []string{}
+	for _, rule := range ingress.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on \"%s/%s\": client certificates cannot be required for rules without a host", id.key(ClientCASecretAnnotation), ingress.Namespace, ingress.Name)
+		}
+		if !stringInArray(host, hosts) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	gateways := []*istionetworkingv1beta1.Gateway{}
+
+	for _, baseGateway := range baseGateways {
+		port := getTLSPortOnGateway(baseGateway)
+
+		gateways = append(gateways, &istionetworkingv1beta1.Gateway{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        generatedName(ingress.Namespace, ingress.Name, baseGateway.Name, "mtls"),
+				Namespace:   baseGateway.Namespace,
+				Labels:      id.ingressReferenceLabels(ingress.Namespace, ingress.Name),
+				Annotations: id.ingressReferenceAnnotations(ingress.Name),
+			},
+			Spec: v1beta1.Gateway{
+				Selector: baseGateway.Spec.Selector,
+				Servers: []*v1beta1.Server{
+					{
+						Port: &v1beta1.Port{
+							Number:   port.Number,
+							Protocol: port.Protocol,
+							Name:     fmt.Sprintf("%s-mtls-%s-%s", strings.ToLower(port.Protocol), ingress.Namespace, ingress.Name),
+						},
+						Hosts: hosts,
+						Tls: &v1beta1.ServerTLSSettings{
+							Mode:           mode,
+							CredentialName: secret,
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return gateways, nil
+}
+
+// Returns the port of the first server terminating TLS on the Gateway,
+// defaulting to HTTPS on port 443.
+func getTLSPortOnGateway(gateway *istionetworkingv1beta1.Gateway) *v1beta1.Port {
+	for _, server := range gateway.Spec.Servers {
+		if server.Port == nil || server.Tls == nil || server.Tls.HttpsRedirect {
+			continue
+		}
+
+		switch server.Tls.Mode {
+		case v1beta1.ServerTLSSettings_SIMPLE, v1beta1.ServerTLSSettings_MUTUAL:
+			return server.Port
+		}
+	}
+
+	return &v1beta1.Port{
+		Number:   443,
+		Protocol: "HTTPS",
+	}
+}
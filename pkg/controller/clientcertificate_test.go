@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestGenerateClientCertificateGateways(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+
+	tests := []struct {
+		name        string
+		host        string
+		annotations map[string]string
+		mode        v1beta1.ServerTLSSettings_TLSmode
+		err         string
+	}{
+		{
+			name:        "default verification",
+			annotations: map[string]string{ClientCASecretAnnotation: "partner-mtls-credential"},
+			mode:        v1beta1.ServerTLSSettings_MUTUAL,
+		},
+		{
+			name:        "mutual verification",
+			annotations: map[string]string{ClientCASecretAnnotation: "partner-mtls-credential", ClientVerificationAnnotation: "mutual"},
+			mode:        v1beta1.ServerTLSSettings_MUTUAL,
+		},
+		{
+			name:        "istio mutual verification",
+			annotations: map[string]string{ClientCASecretAnnotation: "partner-mtls-credential", ClientVerificationAnnotation: "ISTIO_MUTUAL"},
+			err:         "ISTIO_MUTUAL verifies the certificates of the mesh workloads",
+		},
+		{
+			name:        "unknown verification",
+			annotations: map[string]string{ClientCASecretAnnotation: "partner-mtls-credential", ClientVerificationAnnotation: "SIMPLE"},
+			err:         "invalid value for " + ClientVerificationAnnotation,
+		},
+		{
+			name:        "empty secret",
+			annotations: map[string]string{ClientCASecretAnnotation: ""},
+			err:         "secret name is empty",
+		},
+		{
+			name:        "rule without a host",
+			host:        "-",
+			annotations: map[string]string{ClientCASecretAnnotation: "partner-mtls-credential"},
+			err:         "client certificates cannot be required for rules without a host",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host := "a.example.com"
+			if test.host == "-" {
+				host = ""
+			}
+			ingress := testIngress("web", host, "/", "web", http80, test.annotations)
+			base := testGateway("istio-system", "ingressgateway")
+
+			gateways, err := generateClientCertificateGateways(DefaultIdentity(), ingress, []*istionetworkingv1beta1.Gateway{base}, test.annotations[ClientCASecretAnnotation])
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(gateways) != 1 {
+				t.Fatalf("expected 1 gateway, got %d", len(gateways))
+			}
+
+			gateway := gateways[0]
+			if gateway.Namespace != base.Namespace {
+				t.Errorf("expected the gateway in the namespace %q of the base gateway, got %q", base.Namespace, gateway.Namespace)
+			}
+
+			tls := gateway.Spec.Servers[0].Tls
+			if tls.Mode != test.mode || tls.CredentialName != "partner-mtls-credential" {
+				t.Errorf("unexpected tls settings %v", tls)
+			}
+		})
+	}
+}
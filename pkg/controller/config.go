@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Config holds the settings of the controller which can be changed at runtime.
+// The values are in the same format as their command line arguments.
+type Config struct {
+	ClusterDomain        string
+	DefaultGateway       string
+	ScopedGateways       bool
+	PublishStatusAddress string
+	PublishService       string
+	IngressClass         string
+	DefaultWeight        int
+	DefaultExportTo      string
+	DisableRateLimiting  bool
+	// Values of the annotations used for the Ingresses which do not set them
+	AnnotationDefaults map[string]string
+}
+
+// Validate returns an error if the settings are invalid.
+func (cfg *Config) Validate() error {
+	if cfg.DefaultGateway == "" {
+		return fmt.Errorf("the default gateway is required")
+	}
+
+	if len(strings.Split(cfg.DefaultGateway, "/")) > 2 {
+		return fmt.Errorf("invalid default gateway %q: expected the <namespace>/<name> format", cfg.DefaultGateway)
+	}
+
+	if cfg.PublishService != "" && len(strings.Split(cfg.PublishService, "/")) != 2 {
+		return fmt.Errorf("invalid publish service %q: expected the <namespace>/<name> format", cfg.PublishService)
+	}
+
+	if cfg.DefaultWeight < 0 || cfg.DefaultWeight > 100 {
+		return fmt.Errorf("invalid virtual service weight %d: expected a value between 0 and 100", cfg.DefaultWeight)
+	}
+
+	for key := range cfg.AnnotationDefaults {
+		if key == "" {
+			return fmt.Errorf("invalid annotation default: the annotation is required")
+		}
+	}
+
+	return nil
+}
+
+// UpdateConfig applies the settings to the controller and re-enqueues all the Ingresses
+// so that they are reconciled with the new settings.
+func (c *Controller) UpdateConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	c.configLock.Lock()
+	c.applyConfig(cfg)
+	c.configLock.Unlock()
+
+	ingresses, err := c.ingressesLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	klog.InfoS("configuration updated, re-enqueuing ingresses", "ingresses", len(ingresses))
+	for _, ingress := range ingresses {
+		key, err := cache.MetaNamespaceKeyFunc(ingress)
+		if err != nil {
+			return err
+		}
+		c.workqueue.Add(key)
+	}
+
+	return nil
+}
+
+// applyConfig sets the settings of the controller.
+func (c *Controller) applyConfig(cfg Config) {
+	c.clusterDomain = cfg.ClusterDomain
+	c.defaultGateway = cfg.DefaultGateway
+	c.scopedGateways = cfg.ScopedGateways
+	c.publishStatusAddresses = splitList(cfg.PublishStatusAddress)
+	c.publishService = cfg.PublishService
+	c.ingressClass = cfg.IngressClass
+	c.defaultWeight = cfg.DefaultWeight
+	c.defaultExportTo = splitList(cfg.DefaultExportTo)
+	c.disableRateLimiting = cfg.DisableRateLimiting
+	c.annotationDefaults = cfg.AnnotationDefaults
+}
+
+// withAnnotationDefaults returns a copy of the Ingress with the annotation defaults
+// set for the annotations it does not set, or the Ingress itself if there are none.
+func (c *Controller) withAnnotationDefaults(ingress *networkingv1.Ingress) *networkingv1.Ingress {
+	if len(c.annotationDefaults) == 0 {
+		return ingress
+	}
+
+	ingress = ingress.DeepCopy()
+	if ingress.Annotations == nil {
+		ingress.Annotations = map[string]string{}
+	}
+
+	for key, val := range c.annotationDefaults {
+		if _, ok := ingress.Annotations[key]; !ok {
+			ingress.Annotations[key] = val
+		}
+	}
+
+	return ingress
+}
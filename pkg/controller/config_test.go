@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		err  bool
+	}{
+		{
+			name: "valid",
+			cfg:  Config{DefaultGateway: "istio-system/istio-autogenerated-k8s-ingress", PublishService: "istio-system/istio-ingressgateway", DefaultWeight: 100},
+		},
+		{
+			name: "default gateway in the namespace of the ingress",
+			cfg:  Config{DefaultGateway: "ingressgateway"},
+		},
+		{
+			name: "missing default gateway",
+			cfg:  Config{},
+			err:  true,
+		},
+		{
+			name: "invalid default gateway",
+			cfg:  Config{DefaultGateway: "istio-system/ingressgateway/extra"},
+			err:  true,
+		},
+		{
+			name: "publish service without a namespace",
+			cfg:  Config{DefaultGateway: "istio-system/ingressgateway", PublishService: "istio-ingressgateway"},
+			err:  true,
+		},
+		{
+			name: "negative weight",
+			cfg:  Config{DefaultGateway: "istio-system/ingressgateway", DefaultWeight: -1},
+			err:  true,
+		},
+		{
+			name: "weight above 100",
+			cfg:  Config{DefaultGateway: "istio-system/ingressgateway", DefaultWeight: 101},
+			err:  true,
+		},
+		{
+			name: "annotation default without an annotation",
+			cfg:  Config{DefaultGateway: "istio-system/ingressgateway", AnnotationDefaults: map[string]string{"": "value"}},
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.cfg.Validate()
+			if test.err && err == nil {
+				t.Errorf("expected an error")
+			} else if !test.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
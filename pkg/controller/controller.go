@@ -3,17 +3,28 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
-	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	"golang.org/x/time/rate"
+	istionetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiosecurityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
 	istio "istio.io/client-go/pkg/clientset/versioned"
+	istionetworkingv1alpha3informers "istio.io/client-go/pkg/informers/externalversions/networking/v1alpha3"
 	istionetworkinginformers "istio.io/client-go/pkg/informers/externalversions/networking/v1beta1"
+	istiosecurityinformers "istio.io/client-go/pkg/informers/externalversions/security/v1beta1"
+	istionetworkingv1alpha3listers "istio.io/client-go/pkg/listers/networking/v1alpha3"
 	istionetworkinglisters "istio.io/client-go/pkg/listers/networking/v1beta1"
+	istiosecuritylisters "istio.io/client-go/pkg/listers/security/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	networkinginformers "k8s.io/client-go/informers/networking/v1"
 	"k8s.io/client-go/kubernetes"
@@ -24,24 +35,51 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 )
 
-var controllerAgentName = "ingress-istio-controller"
 var controllerAgentVersion = "development"
 
 // Controller responds to new resources and applies the necessary configuration
 type Controller struct {
-	kubeclientset  kubernetes.Interface
-	istioclientset istio.Interface
+	kubeclientset    kubernetes.Interface
+	istioclientset   istio.Interface
+	dynamicclientset dynamic.Interface
+
+	identity *Identity
 
 	clusterDomain  string
 	defaultGateway string
 	scopedGateways bool
-	ingressClass   string
-	defaultWeight  int
+
+	publishStatusAddresses []string
+	publishService         string
+	ingressClass           string
+	defaultWeight          int
+
+	defaultExportTo    []string
+	deterministicNames bool
+
+	disableRateLimiting bool
+
+	// In the dry-run mode, the changes to the VirtualServices and the status of the Ingresses
+	// are reported instead of applied, and the other generated resources are left unchanged
+	dryRun        bool
+	dryRunChanges dryRunChanges
+
+	annotationDefaults map[string]string
+
+	// configLock guards the settings changed at runtime by UpdateConfig,
+	// which are read for the duration of each reconcile.
+	configLock sync.RWMutex
+
+	output             output
+	rootVirtualService string
+
+	istioNetworking *istioNetworking
 
 	ingressesLister  networkinglisters.IngressLister
+	ingressesIndexer cache.Indexer
 	ingressesSynched cache.InformerSynced
 
 	ingressClassesLister  networkinglisters.IngressClassLister
@@ -50,75 +88,186 @@ type Controller struct {
 	servicesLister  corev1listers.ServiceLister
 	servicesSynched cache.InformerSynced
 
+	// The gateway Services are looked up separately, as they may be outside of the watched namespaces
+	gatewayServicesLister  corev1listers.ServiceLister
+	gatewayServicesSynched cache.InformerSynced
+
+	nodesLister  corev1listers.NodeLister
+	nodesSynched cache.InformerSynced
+
 	virtualServicesListers istionetworkinglisters.VirtualServiceLister
 	virtualServicesSynched cache.InformerSynced
 
 	gatewaysListers istionetworkinglisters.GatewayLister
 	gatewaysSynched cache.InformerSynced
 
+	authorizationPoliciesLister  istiosecuritylisters.AuthorizationPolicyLister
+	authorizationPoliciesSynched cache.InformerSynced
+
+	envoyFiltersLister  istionetworkingv1alpha3listers.EnvoyFilterLister
+	envoyFiltersSynched cache.InformerSynced
+
+	serviceEntriesLister  istionetworkinglisters.ServiceEntryLister
+	serviceEntriesSynched cache.InformerSynced
+
+	destinationRulesLister  istionetworkinglisters.DestinationRuleLister
+	destinationRulesSynched cache.InformerSynced
+
+	httpRoutesLister  cache.GenericLister
+	httpRoutesSynched cache.InformerSynced
+
+	gatewayAPIGatewaysLister  cache.GenericLister
+	gatewayAPIGatewaysSynched cache.InformerSynced
+
+	// Namespaces are only watched with a namespace selector
+	namespaceSelector labels.Selector
+	namespacesLister  corev1listers.NamespaceLister
+	namespacesSynched cache.InformerSynced
+
 	workqueue workqueue.RateLimitingInterface
 	recorder  record.EventRecorder
+
+	// Resync-driven reconciles are queued separately and moved to the workqueue at a limited rate,
+	// so that they do not delay the reconcile of changes
+	resyncQueue   workqueue.Interface
+	resyncLimiter *rate.Limiter
+
+	ingressStates    ingressStates
+	reportedWarnings reportedWarnings
+	progress         workerProgress
+	reconcileLogs    reconcileLogs
+}
+
+// Options holds the clients, settings and informers of the controller.
+// The dynamic client and the Gateway API informers are only required in the httproute output mode,
+// and the namespaces informer only with a namespace selector.
+type Options struct {
+	KubeClientset    kubernetes.Interface
+	IstioClientset   istio.Interface
+	DynamicClientset dynamic.Interface
+	Identity         *Identity
+	// Settings which can be changed at runtime with UpdateConfig
+	Config                 Config
+	DeterministicNames     bool
+	DryRun                 bool
+	OutputMode             string
+	RootVirtualService     string
+	IstioNetworkingVersion string
+	// Rate limiter of the workqueue, and rate at which the unchanged Ingresses are reconciled on resync
+	RateLimiter workqueue.RateLimiter
+	ResyncQPS   float64
+
+	IngressesInformer             networkinginformers.IngressInformer
+	IngressClassesInformer        networkinginformers.IngressClassInformer
+	ServicesInformer              corev1informers.ServiceInformer
+	GatewayServicesInformer       corev1informers.ServiceInformer
+	NodesInformer                 corev1informers.NodeInformer
+	VirtualServicesInformer       istionetworkinginformers.VirtualServiceInformer
+	GatewaysInformer              istionetworkinginformers.GatewayInformer
+	AuthorizationPoliciesInformer istiosecurityinformers.AuthorizationPolicyInformer
+	EnvoyFiltersInformer          istionetworkingv1alpha3informers.EnvoyFilterInformer
+	ServiceEntriesInformer        istionetworkinginformers.ServiceEntryInformer
+	DestinationRulesInformer      istionetworkinginformers.DestinationRuleInformer
+	HTTPRoutesInformer            informers.GenericInformer
+	GatewayAPIGatewaysInformer    informers.GenericInformer
+
+	NamespaceSelector  labels.Selector
+	NamespacesInformer corev1informers.NamespaceInformer
 }
 
 // NewController creates a new Controller object.
-func NewController(
-	kubeclientset kubernetes.Interface,
-	istioclientset istio.Interface,
-	clusterDomain string,
-	defaultGateway string,
-	scopedGateways bool,
-	ingressClass string,
-	defaultWeight int,
-	ingressesInformer networkinginformers.IngressInformer,
-	ingressClassesInformer networkinginformers.IngressClassInformer,
-	servicesInformer corev1informers.ServiceInformer,
-	virtualServicesInformer istionetworkinginformers.VirtualServiceInformer,
-	gatewaysInformer istionetworkinginformers.GatewayInformer) *Controller {
-	klog.Infof("setting up controller %s: %s", controllerAgentName, controllerAgentVersion)
+func NewController(opts Options) *Controller {
+	identity := opts.Identity
+	ingressesInformer := opts.IngressesInformer
+
+	klog.Infof("setting up controller %s: %s", identity.managedBy, controllerAgentVersion)
 
 	// Create event broadcaster
 	klog.V(4).Info("creating event broadcaster")
 
 	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeclientset.CoreV1().Events("")})
-	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
+	if opts.DryRun {
+		// Events are only logged in the dry-run mode
+		eventBroadcaster.StartLogging(klog.Infof)
+	} else {
+		eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: opts.KubeClientset.CoreV1().Events("")})
+	}
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: identity.managedBy})
+
+	// The validating webhook looks up the Ingresses by host
+	if err := ingressesInformer.Informer().AddIndexers(cache.Indexers{ingressHostIndex: ingressHostIndexFunc}); err != nil {
+		utilruntime.HandleError(fmt.Errorf("error adding the host index of the ingresses: %v", err))
+	}
 
 	controller := &Controller{
-		kubeclientset:          kubeclientset,
-		istioclientset:         istioclientset,
-		clusterDomain:          clusterDomain,
-		defaultGateway:         defaultGateway,
-		ingressClass:           ingressClass,
-		scopedGateways:         scopedGateways,
-		defaultWeight:          defaultWeight,
-		ingressesLister:        ingressesInformer.Lister(),
-		ingressesSynched:       ingressesInformer.Informer().HasSynced,
-		ingressClassesLister:   ingressClassesInformer.Lister(),
-		ingressClassesSynched:  ingressClassesInformer.Informer().HasSynced,
-		servicesLister:         servicesInformer.Lister(),
-		servicesSynched:        servicesInformer.Informer().HasSynced,
-		virtualServicesListers: virtualServicesInformer.Lister(),
-		virtualServicesSynched: virtualServicesInformer.Informer().HasSynced,
-		gatewaysListers:        gatewaysInformer.Lister(),
-		gatewaysSynched:        gatewaysInformer.Informer().HasSynced,
-		workqueue:              workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "IngressIstio"),
-		recorder:               recorder,
+		kubeclientset:                opts.KubeClientset,
+		istioclientset:               opts.IstioClientset,
+		dynamicclientset:             opts.DynamicClientset,
+		identity:                     identity,
+		deterministicNames:           opts.DeterministicNames,
+		dryRun:                       opts.DryRun,
+		rootVirtualService:           opts.RootVirtualService,
+		ingressesLister:              ingressesInformer.Lister(),
+		ingressesIndexer:             ingressesInformer.Informer().GetIndexer(),
+		ingressesSynched:             ingressesInformer.Informer().HasSynced,
+		ingressClassesLister:         opts.IngressClassesInformer.Lister(),
+		ingressClassesSynched:        opts.IngressClassesInformer.Informer().HasSynced,
+		servicesLister:               opts.ServicesInformer.Lister(),
+		servicesSynched:              opts.ServicesInformer.Informer().HasSynced,
+		gatewayServicesLister:        opts.GatewayServicesInformer.Lister(),
+		gatewayServicesSynched:       opts.GatewayServicesInformer.Informer().HasSynced,
+		nodesLister:                  opts.NodesInformer.Lister(),
+		nodesSynched:                 opts.NodesInformer.Informer().HasSynced,
+		virtualServicesListers:       opts.VirtualServicesInformer.Lister(),
+		virtualServicesSynched:       opts.VirtualServicesInformer.Informer().HasSynced,
+		gatewaysListers:              opts.GatewaysInformer.Lister(),
+		gatewaysSynched:              opts.GatewaysInformer.Informer().HasSynced,
+		authorizationPoliciesLister:  opts.AuthorizationPoliciesInformer.Lister(),
+		authorizationPoliciesSynched: opts.AuthorizationPoliciesInformer.Informer().HasSynced,
+		envoyFiltersLister:           opts.EnvoyFiltersInformer.Lister(),
+		envoyFiltersSynched:          opts.EnvoyFiltersInformer.Informer().HasSynced,
+		serviceEntriesLister:         opts.ServiceEntriesInformer.Lister(),
+		serviceEntriesSynched:        opts.ServiceEntriesInformer.Informer().HasSynced,
+		destinationRulesLister:       opts.DestinationRulesInformer.Lister(),
+		destinationRulesSynched:      opts.DestinationRulesInformer.Informer().HasSynced,
+		workqueue:                    workqueue.NewNamedRateLimitingQueue(opts.RateLimiter, "IngressIstio"),
+		recorder:                     recorder,
+		resyncQueue:                  workqueue.NewNamed("IngressIstioResync"),
+		resyncLimiter:                rate.NewLimiter(rate.Limit(opts.ResyncQPS), 1),
+	}
+	controller.applyConfig(opts.Config)
+
+	controller.output = newOutput(controller, opts.OutputMode)
+	controller.istioNetworking = &istioNetworking{
+		version:          opts.IstioNetworkingVersion,
+		istioclientset:   opts.IstioClientset,
+		dynamicclientset: opts.DynamicClientset,
+		fieldManager:     identity.managedBy,
 	}
 
 	klog.Info("setting up event handlers")
 	ingressesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: controller.enqueueIngress,
 		UpdateFunc: func(old, new interface{}) {
+			ning := new.(*networkingv1.Ingress)
+			oing := old.(*networkingv1.Ingress)
+			if ning.ResourceVersion == oing.ResourceVersion {
+				// Periodic resync will send update events for all known Ingresses.
+				// Unchanged Ingresses are reconciled at a limited rate, repairing the drift of their resources.
+				controller.enqueueIngressResync(new)
+				return
+			}
 			controller.enqueueIngress(new)
 		},
+		DeleteFunc: controller.enqueueIngress,
 	})
 
-	virtualServicesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	opts.VirtualServicesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: controller.handleObject,
 		UpdateFunc: func(old, new interface{}) {
-			nvs := new.(*istionetworkingv1beta1.VirtualService)
-			ovs := old.(*istionetworkingv1beta1.VirtualService)
-			if nvs.ResourceVersion == ovs.ResourceVersion {
+			nvs := new.(metav1.Object)
+			ovs := old.(metav1.Object)
+			if nvs.GetResourceVersion() == ovs.GetResourceVersion() {
 				// Periodic resync will send update events for all known VirtualService.
 				// Two different versions of the same VirtualService will always have different RVs.
 				return
@@ -128,6 +277,112 @@ func NewController(
 		DeleteFunc: controller.handleObject,
 	})
 
+	// Changes to the dependencies of the Ingresses are reconciled as they happen, instead of on the next resync
+	opts.GatewaysInformer.Informer().AddEventHandler(dependencyEventHandler(controller.enqueueIngressesForGateway, gatewaySpecChanged))
+	opts.ServicesInformer.Informer().AddEventHandler(dependencyEventHandler(controller.enqueueIngressesForService, serviceSpecChanged))
+	opts.IngressClassesInformer.Informer().AddEventHandler(dependencyEventHandler(controller.enqueueIngressesForIngressClass, ingressClassSpecChanged))
+	opts.EnvoyFiltersInformer.Informer().AddEventHandler(dependencyEventHandler(controller.enqueueIngressesForRateLimitFilter, envoyFilterSpecChanged))
+
+	opts.GatewaysInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleObject,
+		UpdateFunc: func(old, new interface{}) {
+			ngw := new.(metav1.Object)
+			ogw := old.(metav1.Object)
+			if ngw.GetResourceVersion() == ogw.GetResourceVersion() {
+				return
+			}
+			controller.handleObject(new)
+		},
+		DeleteFunc: controller.handleObject,
+	})
+
+	opts.AuthorizationPoliciesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleObject,
+		UpdateFunc: func(old, new interface{}) {
+			nap := new.(*istiosecurityv1beta1.AuthorizationPolicy)
+			oap := old.(*istiosecurityv1beta1.AuthorizationPolicy)
+			if nap.ResourceVersion == oap.ResourceVersion {
+				return
+			}
+			controller.handleObject(new)
+		},
+		DeleteFunc: controller.handleObject,
+	})
+
+	opts.EnvoyFiltersInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleObject,
+		UpdateFunc: func(old, new interface{}) {
+			nef := new.(*istionetworkingv1alpha3.EnvoyFilter)
+			oef := old.(*istionetworkingv1alpha3.EnvoyFilter)
+			if nef.ResourceVersion == oef.ResourceVersion {
+				return
+			}
+			controller.handleObject(new)
+		},
+		DeleteFunc: controller.handleObject,
+	})
+
+	opts.ServiceEntriesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleObject,
+		UpdateFunc: func(old, new interface{}) {
+			nse := new.(metav1.Object)
+			ose := old.(metav1.Object)
+			if nse.GetResourceVersion() == ose.GetResourceVersion() {
+				return
+			}
+			controller.handleObject(new)
+		},
+		DeleteFunc: controller.handleObject,
+	})
+
+	opts.DestinationRulesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.handleObject,
+		UpdateFunc: func(old, new interface{}) {
+			ndr := new.(metav1.Object)
+			odr := old.(metav1.Object)
+			if ndr.GetResourceVersion() == odr.GetResourceVersion() {
+				return
+			}
+			controller.handleObject(new)
+		},
+		DeleteFunc: controller.handleObject,
+	})
+
+	if opts.HTTPRoutesInformer != nil {
+		controller.httpRoutesLister = opts.HTTPRoutesInformer.Lister()
+		controller.httpRoutesSynched = opts.HTTPRoutesInformer.Informer().HasSynced
+
+		opts.HTTPRoutesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: controller.handleObject,
+			UpdateFunc: func(old, new interface{}) {
+				nhr := new.(metav1.Object)
+				ohr := old.(metav1.Object)
+				if nhr.GetResourceVersion() == ohr.GetResourceVersion() {
+					return
+				}
+				controller.handleObject(new)
+			},
+			DeleteFunc: controller.handleObject,
+		})
+	}
+
+	if opts.GatewayAPIGatewaysInformer != nil {
+		controller.gatewayAPIGatewaysLister = opts.GatewayAPIGatewaysInformer.Lister()
+		controller.gatewayAPIGatewaysSynched = opts.GatewayAPIGatewaysInformer.Informer().HasSynced
+	}
+
+	if opts.NamespaceSelector != nil {
+		controller.namespaceSelector = opts.NamespaceSelector
+		controller.namespacesLister = opts.NamespacesInformer.Lister()
+		controller.namespacesSynched = opts.NamespacesInformer.Informer().HasSynced
+
+		opts.NamespacesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: controller.handleNamespace,
+		})
+	}
+
+	registerMetric(newInformerSyncCollector(controller.informersSynched()))
+
 	return controller
 }
 
@@ -135,38 +390,78 @@ func NewController(
 func (c *Controller) Run(threadiness int, ctx context.Context) error {
 	defer utilruntime.HandleCrash()
 	defer c.workqueue.ShutDown()
+	defer c.resyncQueue.ShutDown()
 
 	klog.Info("starting controller")
 
 	klog.Info("waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(ctx.Done(), c.ingressesSynched, c.ingressClassesSynched, c.servicesSynched, c.virtualServicesSynched, c.gatewaysSynched); !ok {
+	synched := []cache.InformerSynced{}
+	for _, s := range c.informersSynched() {
+		synched = append(synched, s)
+	}
+
+	if ok := cache.WaitForCacheSync(ctx.Done(), synched...); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
 
+	if c.deterministicNames && !c.dryRun {
+		klog.Info("migrating virtual service names")
+		if err := c.migrateVirtualServiceNames(); err != nil {
+			return fmt.Errorf("failed to migrate virtual service names: %v", err)
+		}
+	}
+
 	klog.Info("starting workers")
+	c.progress.start()
+	defer c.progress.stop()
+	var workers sync.WaitGroup
 	for i := 0; i < threadiness; i++ {
-		go wait.Until(c.runWorker, time.Second, ctx.Done())
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			wait.UntilWithContext(ctx, c.runWorker, time.Second)
+		}()
 	}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		wait.UntilWithContext(ctx, c.runResyncWorker, time.Second)
+	}()
 
 	klog.Info("started workers")
 	<-ctx.Done()
-	klog.Info("shutting down workers")
+
+	// The items being processed are completed before returning,
+	// while the items still queued are left to the next leader
+	klog.Info("shutting down workers, draining in-flight work")
+	c.workqueue.ShutDown()
+	c.resyncQueue.ShutDown()
+	workers.Wait()
+	klog.Info("workers drained")
 
 	return nil
 }
 
-func (c *Controller) runWorker() {
-	for c.processNextWorkItem() {
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextWorkItem(ctx) {
 	}
 }
 
-func (c *Controller) processNextWorkItem() bool {
+func (c *Controller) processNextWorkItem(ctx context.Context) bool {
 	obj, shutdown := c.workqueue.Get()
 
 	if shutdown {
 		return false
 	}
 
+	if ctx.Err() != nil {
+		c.workqueue.Done(obj)
+		return false
+	}
+
+	id := c.progress.begin()
+	defer c.progress.done(id)
+
 	err := func(obj interface{}) error {
 		defer c.workqueue.Done(obj)
 		var key string
@@ -178,13 +473,28 @@ func (c *Controller) processNextWorkItem() bool {
 			return nil
 		}
 
+		namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+		c.reconcileLogs.begin(key)
+		defer c.reconcileLogs.end(key)
+
+		start := time.Now()
 		if err := c.syncHandler(key); err != nil {
+			reconcileTotal.WithLabelValues(resultError).Inc()
+			reconcileDuration.WithLabelValues(resultError).Observe(time.Since(start).Seconds())
+			if isTerminalReconcileError(err) {
+				c.workqueue.Forget(obj)
+				klog.ErrorS(err, "reconcile failed, not requeuing", c.logValues(namespace, name, "outcome", resultError, "duration", time.Since(start))...)
+				return nil
+			}
+			klog.ErrorS(err, "reconcile failed, requeuing", c.logValues(namespace, name, "outcome", resultError, "duration", time.Since(start))...)
 			c.workqueue.AddRateLimited(key)
-			return fmt.Errorf("error synching %q: %v, requeing", key, err)
+			return nil
 		}
+		reconcileTotal.WithLabelValues(resultSuccess).Inc()
+		reconcileDuration.WithLabelValues(resultSuccess).Observe(time.Since(start).Seconds())
 
 		c.workqueue.Forget(obj)
-		klog.Infof("successfully synched %q", key)
+		klog.InfoS("successfully synched", c.logValues(namespace, name, "outcome", resultSuccess, "duration", time.Since(start))...)
 		return nil
 	}(obj)
 
@@ -203,29 +513,48 @@ func (c *Controller) syncHandler(key string) error {
 		return nil
 	}
 
+	c.configLock.RLock()
+	defer c.configLock.RUnlock()
+
 	// Get the ingress object
 	ingress, err := c.ingressesLister.Ingresses(namespace).Get(name)
 	if err != nil {
 		if errors.IsNotFound(err) {
-			utilruntime.HandleError(fmt.Errorf("ingress %q in work queue no longer exists", key))
-			return nil
+			// Resources outside of the Ingress' namespace are not garbage collected
+			klog.V(4).InfoS("ingress in work queue no longer exists, cleaning up", c.logValues(namespace, name)...)
+			c.ingressStates.set(key, "")
+			c.reportedWarnings.forget(key)
+			if c.dryRun {
+				c.dryRunChanges.set(key, "", nil)
+				return nil
+			}
+			return c.removeResourcesForIngress(namespace, name)
 		}
 
 		return err
 	}
 
-	// Handle the VirtualService
-	vs, err := c.handleVirtualServiceForIngress(ingress)
+	ingress = c.withAnnotationDefaults(ingress)
+
+	// Handle the routing resources of the output
+	loadBalancerStatus, err := c.output.sync(ingress)
 	if err != nil {
-		klog.Errorf("failed to handle virtual service: %v", err)
+		c.recordReconcileError(ingress, err)
 		return err
 	}
 
+	if loadBalancerStatus == nil {
+		c.ingressStates.set(key, "ignored")
+	}
+
 	// If the Ingress was handled, update its status.
-	if vs != nil {
-		_, err = c.handleIngressStatus(ingress, vs)
+	if loadBalancerStatus != nil {
+		c.ingressStates.set(key, "handled")
+		c.recordMissingBackendServices(ingress)
+
+		_, err = c.handleIngressStatus(ingress, *loadBalancerStatus)
 		if err != nil {
-			klog.Errorf("failed to handle Ingress status: %v", err)
+			klog.ErrorS(err, "failed to handle Ingress status", c.logValues(namespace, name)...)
 			return err
 		}
 	}
@@ -236,11 +565,17 @@ func (c *Controller) syncHandler(key string) error {
 func (c *Controller) enqueueIngress(obj interface{}) {
 	var key string
 	var err error
-	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
+	if key, err = cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err != nil {
 		utilruntime.HandleError(err)
 		return
 	}
 
+	// Ingresses of namespaces not matching the namespace selector are ignored,
+	// until their namespace starts matching
+	if namespace, _, err := cache.SplitMetaNamespaceKey(key); err == nil && !c.namespaceMatches(namespace) {
+		return
+	}
+
 	c.workqueue.Add(key)
 }
 
@@ -258,9 +593,9 @@ func (c *Controller) handleObject(obj interface{}) {
 			utilruntime.HandleError(fmt.Errorf("error decoding object tombstone, invalid type"))
 			return
 		}
-		klog.V(4).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
+		klog.V(4).InfoS("recovered deleted object from tombstone", "object", klog.KObj(object))
 	}
-	klog.V(4).Infof("Processing object: %s", object.GetName())
+	klog.V(4).InfoS("processing object", "object", klog.KObj(object))
 	if ownerRef := metav1.GetControllerOf(object); ownerRef != nil {
 		// If this object is not owned by an Ingress, we should not do anything more
 		// with it.
@@ -270,11 +605,17 @@ func (c *Controller) handleObject(obj interface{}) {
 
 		ingress, err := c.ingressesLister.Ingresses(object.GetNamespace()).Get(ownerRef.Name)
 		if err != nil {
-			klog.V(4).Infof("ignoring orphaned object '%s' of ingress '%s'", object.GetSelfLink(), ownerRef.Name)
+			klog.V(4).InfoS("ignoring orphaned object", "object", klog.KObj(object), "ingress", ownerRef.Name)
 			return
 		}
 
 		c.enqueueIngress(ingress)
 		return
 	}
+
+	// Objects outside of the Ingress' namespace reference it through labels.
+	// The Ingress is enqueued even if it no longer exists, so that the object is cleaned up.
+	if namespace, name, ok := c.identity.getIngressReference(object); ok {
+		c.workqueue.Add(fmt.Sprintf("%s/%s", namespace, name))
+	}
 }
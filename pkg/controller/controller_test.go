@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"testing"
+
+	istionetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istiosecurityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
+	istionetworkingv1alpha3listers "istio.io/client-go/pkg/listers/networking/v1alpha3"
+	istionetworkinglisters "istio.io/client-go/pkg/listers/networking/v1beta1"
+	istiosecuritylisters "istio.io/client-go/pkg/listers/security/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// newTestController returns a controller in the virtualservice output mode whose listers hold the objects
+// and whose caches have synced. It has no clients, so only the translation can be tested.
+func newTestController(t *testing.T, cfg Config, objects ...runtime.Object) *Controller {
+	t.Helper()
+
+	indexers := map[string]cache.Indexer{}
+	indexer := func(kind string) cache.Indexer {
+		if _, ok := indexers[kind]; !ok {
+			indexers[kind] = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		}
+		return indexers[kind]
+	}
+
+	if err := indexer("Ingress").AddIndexers(cache.Indexers{ingressHostIndex: ingressHostIndexFunc}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, obj := range objects {
+		var kind string
+		switch obj.(type) {
+		case *networkingv1.Ingress:
+			kind = "Ingress"
+		case *networkingv1.IngressClass:
+			kind = "IngressClass"
+		case *corev1.Service:
+			kind = "Service"
+		case *corev1.Node:
+			kind = "Node"
+		case *istionetworkingv1beta1.VirtualService:
+			kind = "VirtualService"
+		case *istionetworkingv1beta1.Gateway:
+			kind = "Gateway"
+		case *istionetworkingv1beta1.ServiceEntry:
+			kind = "ServiceEntry"
+		case *istionetworkingv1beta1.DestinationRule:
+			kind = "DestinationRule"
+		case *istiosecurityv1beta1.AuthorizationPolicy:
+			kind = "AuthorizationPolicy"
+		case *istionetworkingv1alpha3.EnvoyFilter:
+			kind = "EnvoyFilter"
+		default:
+			t.Fatalf("unexpected object %T", obj)
+		}
+
+		if err := indexer(kind).Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	synched := func() bool { return true }
+	c := &Controller{
+		identity:                     DefaultIdentity(),
+		ingressesLister:              networkinglisters.NewIngressLister(indexer("Ingress")),
+		ingressesIndexer:             indexer("Ingress"),
+		ingressesSynched:             synched,
+		ingressClassesLister:         networkinglisters.NewIngressClassLister(indexer("IngressClass")),
+		ingressClassesSynched:        synched,
+		servicesLister:               corev1listers.NewServiceLister(indexer("Service")),
+		servicesSynched:              synched,
+		gatewayServicesLister:        corev1listers.NewServiceLister(indexer("Service")),
+		gatewayServicesSynched:       synched,
+		nodesLister:                  corev1listers.NewNodeLister(indexer("Node")),
+		nodesSynched:                 synched,
+		virtualServicesListers:       istionetworkinglisters.NewVirtualServiceLister(indexer("VirtualService")),
+		virtualServicesSynched:       synched,
+		gatewaysListers:              istionetworkinglisters.NewGatewayLister(indexer("Gateway")),
+		gatewaysSynched:              synched,
+		authorizationPoliciesLister:  istiosecuritylisters.NewAuthorizationPolicyLister(indexer("AuthorizationPolicy")),
+		authorizationPoliciesSynched: synched,
+		envoyFiltersLister:           istionetworkingv1alpha3listers.NewEnvoyFilterLister(indexer("EnvoyFilter")),
+		envoyFiltersSynched:          synched,
+		serviceEntriesLister:         istionetworkinglisters.NewServiceEntryLister(indexer("ServiceEntry")),
+		serviceEntriesSynched:        synched,
+		destinationRulesLister:       istionetworkinglisters.NewDestinationRuleLister(indexer("DestinationRule")),
+		destinationRulesSynched:      synched,
+		recorder:                     record.NewFakeRecorder(100),
+	}
+	c.output = newOutput(c, VirtualServiceOutputMode)
+
+	if cfg.ClusterDomain == "" {
+		cfg.ClusterDomain = "cluster.local"
+	}
+	if cfg.DefaultWeight == 0 {
+		cfg.DefaultWeight = 100
+	}
+	c.applyConfig(cfg)
+
+	return c
+}
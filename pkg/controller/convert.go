@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istionetworkingv1alpha3listers "istio.io/client-go/pkg/listers/networking/v1alpha3"
+	istionetworkinglisters "istio.io/client-go/pkg/listers/networking/v1beta1"
+	istiosecuritylisters "istio.io/client-go/pkg/listers/security/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// The reason of the problems of the gateways without addresses, which Convert reports
+// since no load balancer can assign them.
+const reasonUnresolvedGatewayAddress = "UnresolvedGatewayAddress"
+
+// ConvertResult is the translation of Ingresses by Convert.
+type ConvertResult struct {
+	// The VirtualServices generated for the handled Ingresses
+	VirtualServices []*istionetworkingv1beta1.VirtualService
+	// The handled Ingresses, with the status the controller would publish
+	Ingresses []*networkingv1.Ingress
+	// The references of the Ingresses which could not be resolved,
+	// such as unknown gateways and backend services
+	Problems []string
+}
+
+// Convert translates the Ingresses among the objects into VirtualServices with the settings,
+// as the controller does in the virtualservice output mode. The references of the Ingresses
+// are resolved against the IngressClasses, Services, Nodes and Gateways among the objects instead of a cluster.
+// Objects without a namespace are in the default namespace, as with kubectl. Objects of other kinds are ignored.
+func Convert(identity *Identity, cfg Config, deterministicNames bool, objects []*unstructured.Unstructured) (*ConvertResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	indexers := map[string]cache.Indexer{}
+	indexer := func(kind string) cache.Indexer {
+		if _, ok := indexers[kind]; !ok {
+			indexers[kind] = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		}
+		return indexers[kind]
+	}
+
+	for _, obj := range objects {
+		var typed runtime.Object
+		var err error
+
+		if obj.GetNamespace() == "" {
+			obj = obj.DeepCopy()
+			obj.SetNamespace(metav1.NamespaceDefault)
+		}
+
+		gvk := obj.GroupVersionKind()
+		switch {
+		case gvk.Group == networkingv1.GroupName && gvk.Kind == "Ingress":
+			typed, err = fromUnstructured[networkingv1.Ingress](obj)
+		case gvk.Group == networkingv1.GroupName && gvk.Kind == "IngressClass":
+			obj.SetNamespace("")
+			typed, err = fromUnstructured[networkingv1.IngressClass](obj)
+		case gvk.Group == corev1.GroupName && gvk.Kind == "Service":
+			typed, err = fromUnstructured[corev1.Service](obj)
+		case gvk.Group == corev1.GroupName && gvk.Kind == "Node":
+			obj.SetNamespace("")
+			typed, err = fromUnstructured[corev1.Node](obj)
+		case gvk.Group == istioNetworkingGroup && gvk.Kind == "Gateway":
+			typed, err = fromUnstructured[istionetworkingv1beta1.Gateway](obj)
+		default:
+			klog.V(4).InfoS("ignoring object", "kind", gvk.Kind, "object", klog.KObj(obj))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error converting %s %q: %v", gvk.Kind, obj.GetName(), err)
+		}
+
+		if err := indexer(gvk.Kind).Add(typed); err != nil {
+			return nil, err
+		}
+	}
+
+	events := &eventCollector{}
+	c := &Controller{
+		identity:                    identity,
+		deterministicNames:          deterministicNames,
+		ingressesLister:             networkinglisters.NewIngressLister(indexer("Ingress")),
+		ingressClassesLister:        networkinglisters.NewIngressClassLister(indexer("IngressClass")),
+		servicesLister:              corev1listers.NewServiceLister(indexer("Service")),
+		gatewayServicesLister:       corev1listers.NewServiceLister(indexer("Service")),
+		nodesLister:                 corev1listers.NewNodeLister(indexer("Node")),
+		virtualServicesListers:      istionetworkinglisters.NewVirtualServiceLister(indexer("VirtualService")),
+		gatewaysListers:             istionetworkinglisters.NewGatewayLister(indexer("Gateway")),
+		authorizationPoliciesLister: istiosecuritylisters.NewAuthorizationPolicyLister(indexer("AuthorizationPolicy")),
+		envoyFiltersLister:          istionetworkingv1alpha3listers.NewEnvoyFilterLister(indexer("EnvoyFilter")),
+		serviceEntriesLister:        istionetworkinglisters.NewServiceEntryLister(indexer("ServiceEntry")),
+		destinationRulesLister:      istionetworkinglisters.NewDestinationRuleLister(indexer("DestinationRule")),
+		recorder:                    events,
+	}
+	c.applyConfig(cfg)
+
+	ingresses, err := c.ingressesLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ingresses, func(i, j int) bool {
+		return fmt.Sprintf("%s/%s", ingresses[i].Namespace, ingresses[i].Name) < fmt.Sprintf("%s/%s", ingresses[j].Namespace, ingresses[j].Name)
+	})
+
+	result := &ConvertResult{}
+	for _, ingress := range ingresses {
+		vs, status, err := c.convertIngress(c.withAnnotationDefaults(ingress))
+		if err != nil {
+			// Errors of the listers are unresolved references, such as an unknown IngressClass.
+			// The missing backend services were all recorded with their own Events.
+			var rerr *reconcileError
+			if errors.As(err, &rerr) {
+				if rerr.reason != ReasonMissingBackendService {
+					c.recordReconcileError(ingress, err)
+				}
+			} else {
+				events.warnings = append(events.warnings, fmt.Sprintf("%s/%s: %v", ingress.Namespace, ingress.Name, err))
+			}
+			continue
+		}
+
+		if vs == nil {
+			continue
+		}
+
+		vs.TypeMeta = metav1.TypeMeta{APIVersion: istionetworkingv1beta1.SchemeGroupVersion.String(), Kind: "VirtualService"}
+		result.VirtualServices = append(result.VirtualServices, vs)
+
+		ingress = ingress.DeepCopy()
+		ingress.TypeMeta = metav1.TypeMeta{APIVersion: networkingv1.SchemeGroupVersion.String(), Kind: "Ingress"}
+		ingress.Status.LoadBalancer = *status
+		result.Ingresses = append(result.Ingresses, ingress)
+	}
+	result.Problems = events.warnings
+
+	return result, nil
+}
+
+// convertIngress returns the VirtualService generated for the Ingress and the status of the Ingress,
+// or nil if the Ingress is not handled.
+func (c *Controller) convertIngress(ingress *networkingv1.Ingress) (*istionetworkingv1beta1.VirtualService, *corev1.LoadBalancerStatus, error) {
+	handle, reason, err := c.getIngressHandling(ingress)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !handle {
+		klog.InfoS("skipping ingress", "namespace", ingress.Namespace, "name", ingress.Name, "reason", reason)
+		return nil, nil, nil
+	}
+
+	_, gateways, err := c.getClientCertificateGatewaysForIngress(ingress, c.getGatewayNamesForIngress(ingress))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolved, err := c.getGatewaysByName(gateways, ingress.Namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.recordUnknownGateways(ingress, gateways, resolved, ingress.Namespace)
+	c.recordMissingBackendServices(ingress)
+
+	vs, err := c.generateVirtualService(ingress, nil, gateways)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The controller waits for the addresses of the gateways, which cannot appear without a cluster
+	status := &corev1.LoadBalancerStatus{}
+	for _, gateway := range resolved {
+		ingresses, err := c.getLoadBalancerIngressForGateway(gateway)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(ingresses) == 0 {
+			c.recorder.Eventf(ingress, corev1.EventTypeWarning, reasonUnresolvedGatewayAddress, "Gateway \"%s/%s\" has no address", gateway.Namespace, gateway.Name)
+		}
+
+		status.Ingress = append(status.Ingress, ingresses...)
+	}
+
+	return vs, status, nil
+}
+
+// eventCollector is a record.EventRecorder collecting the Warning Events,
+// which report the references of the Ingresses which could not be resolved.
+type eventCollector struct {
+	warnings []string
+}
+
+func (e *eventCollector) Event(object runtime.Object, eventtype, reason, message string) {
+	if eventtype != corev1.EventTypeWarning {
+		return
+	}
+
+	if obj, ok := object.(metav1.Object); ok {
+		e.warnings = append(e.warnings, fmt.Sprintf("%s/%s: %s: %s", obj.GetNamespace(), obj.GetName(), reason, message))
+	} else {
+		e.warnings = append(e.warnings, fmt.Sprintf("%s: %s", reason, message))
+	}
+}
+
+func (e *eventCollector) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	e.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (e *eventCollector) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	e.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
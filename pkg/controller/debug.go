@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ingressDebugInfo explains how the controller translates an Ingress.
+type ingressDebugInfo struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Whether the Ingress is handled and why
+	Handled bool   `json:"handled"`
+	Reason  string `json:"reason"`
+	// The gateways resolved for the Ingress and their ports not redirecting to HTTPS
+	Gateways     []string `json:"gateways,omitempty"`
+	GatewayPorts []uint32 `json:"gatewayPorts,omitempty"`
+	// The VirtualService generated for the Ingress, the one in the cluster and the differences between them
+	DesiredVirtualService *istionetworkingv1beta1.VirtualService `json:"desiredVirtualService,omitempty"`
+	LiveVirtualService    *istionetworkingv1beta1.VirtualService `json:"liveVirtualService,omitempty"`
+	Diff                  string                                 `json:"diff,omitempty"`
+	// The error which prevents the translation of the Ingress
+	Error string `json:"error,omitempty"`
+}
+
+// DebugIngressHandler returns the handler explaining the translation of the Ingresses,
+// served under the /debug/ingresses/<namespace>/<name> path.
+// The translation is computed from the informer caches and nothing is written to the cluster.
+func (c *Controller) DebugIngressHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/debug/ingresses/"), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, "expected /debug/ingresses/<namespace>/<name>", http.StatusBadRequest)
+			return
+		}
+
+		ingress, err := c.ingressesLister.Ingresses(parts[0]).Get(parts[1])
+		if errors.IsNotFound(err) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		c.configLock.RLock()
+		info := c.debugIngress(c.withAnnotationDefaults(ingress))
+		c.configLock.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(info); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// debugIngress computes the translation of the Ingress without applying it.
+func (c *Controller) debugIngress(ingress *networkingv1.Ingress) *ingressDebugInfo {
+	info := &ingressDebugInfo{
+		Namespace: ingress.Namespace,
+		Name:      ingress.Name,
+	}
+
+	var err error
+	info.Handled, info.Reason, err = c.getIngressHandling(ingress)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.LiveVirtualService, err = c.findExistingVirtualServiceForIngress(ingress)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	if !info.Handled {
+		return info
+	}
+
+	if _, ok := c.output.(*httpRouteOutput); ok {
+		info.Gateways = c.getGatewayNamesForIngress(ingress)
+		return info
+	}
+
+	if c.rootVirtualService != "" {
+		info.Gateways, err = c.getRootGatewayNames()
+	} else {
+		_, info.Gateways, err = c.getClientCertificateGatewaysForIngress(ingress, c.getGatewayNamesForIngress(ingress))
+	}
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	gateways, err := c.getGatewaysByName(info.Gateways, ingress.Namespace)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.GatewayPorts = c.getNonHTTPPRedirectPortsOnGateways(gateways)
+
+	existing := info.LiveVirtualService
+	if existing == nil {
+		existing, err = c.findVirtualServiceToAdopt(ingress)
+		if err != nil {
+			info.Error = err.Error()
+			return info
+		}
+		info.LiveVirtualService = existing
+	}
+
+	info.DesiredVirtualService, err = c.generateVirtualService(ingress, existing, info.Gateways)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.Diff, err = diffVirtualServices(info.LiveVirtualService, info.DesiredVirtualService)
+	if err != nil {
+		info.Error = err.Error()
+	}
+
+	return info
+}
+
+// diffVirtualServices returns the differences between the metadata managed by the controller
+// and the spec of the live and desired VirtualServices, in the JSON representation of the objects.
+func diffVirtualServices(live, desired *istionetworkingv1beta1.VirtualService) (string, error) {
+	normalize := func(vs *istionetworkingv1beta1.VirtualService) (map[string]interface{}, error) {
+		if vs == nil {
+			return nil, nil
+		}
+
+		data, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":            vs.Name,
+				"namespace":       vs.Namespace,
+				"labels":          vs.Labels,
+				"annotations":     vs.Annotations,
+				"ownerReferences": vs.OwnerReferences,
+			},
+			// MarshalJSON has a pointer receiver and writes the oneof fields as in the API
+			"spec": &vs.Spec,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		out := map[string]interface{}{}
+		err = json.Unmarshal(data, &out)
+		return out, err
+	}
+
+	l, err := normalize(live)
+	if err != nil {
+		return "", err
+	}
+
+	d, err := normalize(desired)
+	if err != nil {
+		return "", err
+	}
+
+	return cmp.Diff(l, d), nil
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffVirtualServices(t *testing.T) {
+	virtualService := func(prefix string) *istionetworkingv1beta1.VirtualService {
+		return &istionetworkingv1beta1.VirtualService{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"},
+			Spec: v1beta1.VirtualService{
+				Http: []*v1beta1.HTTPRoute{{
+					Match: []*v1beta1.HTTPMatchRequest{{Uri: &v1beta1.StringMatch{MatchType: &v1beta1.StringMatch_Prefix{Prefix: prefix}}}},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		live    *istionetworkingv1beta1.VirtualService
+		desired *istionetworkingv1beta1.VirtualService
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			live:    virtualService("/"),
+			desired: virtualService("/"),
+		},
+		{
+			name:    "changed match",
+			live:    virtualService("/"),
+			desired: virtualService("/api"),
+			want:    []string{`"prefix"`, `"/api"`},
+		},
+		{
+			name:    "created",
+			desired: virtualService("/"),
+			want:    []string{`"spec"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff, err := diffVirtualServices(test.live, test.desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(test.want) == 0 && diff != "" {
+				t.Errorf("expected no difference, got:\n%s", diff)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(diff, want) {
+					t.Errorf("expected the difference to contain %s, got:\n%s", want, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// delegateVirtualServiceOutput translates Ingresses into delegate VirtualServices
+// which are attached to the gateways through the routes of a root VirtualService.
+type delegateVirtualServiceOutput struct {
+	controller *Controller
+}
+
+func (o *delegateVirtualServiceOutput) sync(ingress *networkingv1.Ingress) (*corev1.LoadBalancerStatus, error) {
+	c := o.controller
+
+	// Handle the delegate VirtualService
+	vs, err := c.handleVirtualServiceForIngress(ingress)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle virtual service", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	// Attach the delegate to the root VirtualService
+	err = c.handleRootVirtualServiceForIngress(ingress, vs)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle root virtual service", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	// The gateways of the Ingress are the gateways of the root VirtualService
+	var root *istionetworkingv1beta1.VirtualService
+	if vs != nil {
+		root, err = c.getRootVirtualService()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Handle the external authorization for the Ingress
+	err = c.handleAuthorizationPoliciesForIngress(ingress, root)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle authorization policies", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	// Handle the rate limit for the Ingress
+	err = c.handleRateLimitFiltersForIngress(ingress, root)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle rate limit filters", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	// Handle the ExternalName Services referenced by the Ingress
+	err = c.handleExternalServicesForIngress(ingress, vs != nil)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle external services", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	if root == nil {
+		return nil, nil
+	}
+
+	loadBalancerStatus, err := c.getLoadBalancerStatusForVirtualService(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loadBalancerStatus, nil
+}
+
+func (o *delegateVirtualServiceOutput) status(ingress *networkingv1.Ingress) (*corev1.LoadBalancerStatus, error) {
+	c := o.controller
+
+	vs, err := c.findExistingVirtualServiceForIngress(ingress)
+	if err != nil || vs == nil {
+		return nil, err
+	}
+
+	root, err := c.getRootVirtualService()
+	if err != nil {
+		return nil, err
+	}
+
+	loadBalancerStatus, err := c.getLoadBalancerStatusForVirtualService(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loadBalancerStatus, nil
+}
+
+// splitRootVirtualService returns the namespace and name of the root VirtualService.
+func (c *Controller) splitRootVirtualService() (namespace, name string) {
+	parts := strings.SplitN(c.rootVirtualService, "/", 2)
+	return parts[0], parts[1]
+}
+
+// getRootVirtualService returns the root VirtualService to which the delegates are attached.
+func (c *Controller) getRootVirtualService() (*istionetworkingv1beta1.VirtualService, error) {
+	namespace, name := c.splitRootVirtualService()
+
+	return c.virtualServicesListers.VirtualServices(namespace).Get(name)
+}
+
+// getRootGatewayNames returns the gateways of the root VirtualService,
+// qualified with the namespace of the root VirtualService.
+func (c *Controller) getRootGatewayNames() ([]string, error) {
+	root, err := c.getRootVirtualService()
+	if err != nil {
+		return nil, err
+	}
+
+	gateways := make([]string, len(root.Spec.Gateways))
+	for i, gateway := range root.Spec.Gateways {
+		if !strings.Contains(gateway, "/") {
+			gateway = fmt.Sprintf("%s/%s", root.Namespace, gateway)
+		}
+		gateways[i] = gateway
+	}
+
+	return gateways, nil
+}
+
+// handleRootVirtualServiceForIngress synchronizes the delegate route of the Ingress
+// in the root VirtualService. If vs is nil, the route of the Ingress is removed.
+func (c *Controller) handleRootVirtualServiceForIngress(ingress *networkingv1.Ingress, vs *istionetworkingv1beta1.VirtualService) error {
+	// Left unchanged in the dry-run mode
+	if c.dryRun {
+		return nil
+	}
+
+	var route *v1beta1.HTTPRoute
+	if vs != nil {
+		route = generateDelegateRoute(c.identity, ingress, vs)
+	}
+
+	return c.updateRootVirtualService(ingress.Namespace, ingress.Name, route)
+}
+
+// updateRootVirtualService replaces the route of the Ingress in the root VirtualService,
+// or removes it if route is nil. Routes which are not managed by the controller are left untouched.
+func (c *Controller) updateRootVirtualService(namespace, name string, route *v1beta1.HTTPRoute) error {
+	ctx := context.Background()
+
+	rootNamespace, rootName := c.splitRootVirtualService()
+
+	root, err := c.getRootVirtualService()
+	if errors.IsNotFound(err) && route == nil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// The root is fetched from the API server when a conflicting update
+	// was made since it was observed by the informer.
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if root == nil {
+			root, err = c.istioNetworking.VirtualServices(rootNamespace).Get(ctx, rootName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+		}
+
+		http := generateRootRoutes(c.identity, root.Spec.Http, namespace, name, route, c.delegateRoutePriority)
+		if reflect.DeepEqual(root.Spec.Http, http) {
+			return nil
+		}
+
+		klog.InfoS("updating root virtual service", c.logValues(namespace, name, "rootVirtualService", klog.KObj(root))...)
+
+		updated := root.DeepCopy()
+		updated.Spec.Http = http
+
+		_, err = c.istioNetworking.VirtualServices(root.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+		if err == nil {
+			virtualServiceOperationsTotal.WithLabelValues("update").Inc()
+		}
+		root = nil
+		return err
+	})
+}
+
+// renameRootVirtualServiceDelegate points the route of the Ingress in the root VirtualService,
+// if it has one, to its renamed VirtualService.
+func (c *Controller) renameRootVirtualServiceDelegate(ingress *networkingv1.Ingress, vs *istionetworkingv1beta1.VirtualService) error {
+	if c.rootVirtualService == "" {
+		return nil
+	}
+
+	root, err := c.getRootVirtualService()
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, route := range root.Spec.Http {
+		if route.Name != c.identity.delegateRouteName(ingress.Namespace, ingress.Name) || route.Delegate == nil {
+			continue
+		}
+
+		if route.Delegate.Name == vs.Name && route.Delegate.Namespace == vs.Namespace {
+			return nil
+		}
+
+		renamed := route.DeepCopy()
+		renamed.Delegate.Name = vs.Name
+		renamed.Delegate.Namespace = vs.Namespace
+
+		return c.updateRootVirtualService(ingress.Namespace, ingress.Name, renamed)
+	}
+
+	return nil
+}
+
+// removeRootVirtualServiceRouteForIngress removes the delegate route of a deleted Ingress from the root VirtualService.
+func (c *Controller) removeRootVirtualServiceRouteForIngress(namespace, name string) error {
+	if c.rootVirtualService == "" {
+		return nil
+	}
+
+	return c.updateRootVirtualService(namespace, name, nil)
+}
+
+// delegateRouteName returns the name of the route of the Ingress in the root VirtualService,
+// which identifies the routes managed by the controller.
+func (id *Identity) delegateRouteName(namespace, name string) string {
+	return fmt.Sprintf("%s:%s/%s", id.managedBy, namespace, name)
+}
+
+// generateDelegateRoute generates the route of the root VirtualService delegating
+// the traffic for the hosts of the Ingress to its VirtualService.
+func generateDelegateRoute(id *Identity, ingress *networkingv1.Ingress, vs *istionetworkingv1beta1.VirtualService) *v1beta1.HTTPRoute {
+	route := &v1beta1.HTTPRoute{
+		Name: id.delegateRouteName(ingress.Namespace, ingress.Name),
+		Delegate: &v1beta1.Delegate{
+			Name:      vs.Name,
+			Namespace: vs.Namespace,
+		},
+	}
+
+	// The matches of the delegate are a subset of the authority matches of the root,
+	// which cover the host with or without a port.
+	hosts := []string{}
+	for _, rule := range ingress.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+		if !stringInArray(host, hosts) {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		route.Match = append(route.Match, &v1beta1.HTTPMatchRequest{
+			Authority: &v1beta1.StringMatch{
+				MatchType: &v1beta1.StringMatch_Regex{
+					Regex: "^" + strings.ReplaceAll(regexp.QuoteMeta(host), "\\*", ".*") + "(:[0-9]+)?$",
+				},
+			},
+		})
+	}
+
+	return route
+}
+
+// generateRootRoutes returns the routes of the root VirtualService with the route of the Ingress
+// replaced by route, or removed if route is nil. The routes managed by the controller
+// are ordered ahead of the other routes of the root VirtualService, so that they are not
+// shadowed by its catch-all routes. As the first route matching a request handles it,
+// they are ordered by their priority and then by name, so that their order is deterministic.
+func generateRootRoutes(id *Identity, existing []*v1beta1.HTTPRoute, namespace, name string, route *v1beta1.HTTPRoute, priority func(routeName string) delegateRoutePriority) []*v1beta1.HTTPRoute {
+	managed := []*v1beta1.HTTPRoute{}
+	unmanaged := []*v1beta1.HTTPRoute{}
+
+	routeName := id.delegateRouteName(namespace, name)
+	for _, r := range existing {
+		if r.Name == routeName {
+			continue
+		}
+
+		if strings.HasPrefix(r.Name, id.managedBy+":") && r.Delegate != nil {
+			managed = append(managed, r)
+		} else {
+			unmanaged = append(unmanaged, r)
+		}
+	}
+
+	if route != nil {
+		managed = append(managed, route)
+	}
+
+	priorities := map[string]delegateRoutePriority{}
+	for _, r := range managed {
+		priorities[r.Name] = priority(r.Name)
+	}
+
+	sort.SliceStable(managed, func(i, j int) bool {
+		pi, pj := priorities[managed[i].Name], priorities[managed[j].Name]
+		if pi != pj {
+			return pi.before(pj)
+		}
+		return managed[i].Name < managed[j].Name
+	})
+
+	return append(managed, unmanaged...)
+}
+
+// delegateRoutePriority is the priority of the route of an Ingress in the root VirtualService.
+type delegateRoutePriority struct {
+	// The specificity of the most specific host of the Ingress
+	hostSpecificity int
+	// The length of the longest path of the Ingress
+	pathLength int
+}
+
+// before determines if the route of priority p precedes the route of priority o:
+// routes of more specific hosts come first, then those of longer paths,
+// so that they are not shadowed by the routes of the Ingresses serving the rest of the host.
+func (p delegateRoutePriority) before(o delegateRoutePriority) bool {
+	if p.hostSpecificity != o.hostSpecificity {
+		return p.hostSpecificity > o.hostSpecificity
+	}
+
+	return p.pathLength > o.pathLength
+}
+
+// hostSpecificity ranks the hosts of the Ingresses: exact hosts ahead of wildcard hosts,
+// and longer wildcard hosts ahead of shorter ones.
+func hostSpecificity(host string) int {
+	if host == "" || strings.Contains(host, "*") {
+		return len(host)
+	}
+
+	return validation.DNS1123SubdomainMaxLength + len(host)
+}
+
+// ingressRoutePriority returns the priority of the route of the Ingress in the root VirtualService.
+func ingressRoutePriority(ingress *networkingv1.Ingress) delegateRoutePriority {
+	priority := delegateRoutePriority{}
+
+	for _, rule := range ingress.Spec.Rules {
+		if specificity := hostSpecificity(rule.Host); specificity > priority.hostSpecificity {
+			priority.hostSpecificity = specificity
+		}
+
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if len(path.Path) > priority.pathLength {
+				priority.pathLength = len(path.Path)
+			}
+		}
+	}
+
+	return priority
+}
+
+// delegateRoutePriority returns the priority of the route of the root VirtualService of the given name,
+// from the Ingress it delegates to. Routes of Ingresses which no longer exist have the lowest priority.
+func (c *Controller) delegateRoutePriority(routeName string) delegateRoutePriority {
+	parts := strings.SplitN(strings.TrimPrefix(routeName, c.identity.managedBy+":"), "/", 2)
+	if len(parts) != 2 {
+		return delegateRoutePriority{}
+	}
+
+	ingress, err := c.ingressesLister.Ingresses(parts[0]).Get(parts[1])
+	if err != nil {
+		return delegateRoutePriority{}
+	}
+
+	return ingressRoutePriority(ingress)
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGenerateDelegateRoute(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+	vs := &istionetworkingv1beta1.VirtualService{ObjectMeta: metav1.ObjectMeta{Name: "web-vs", Namespace: "app"}}
+
+	tests := []struct {
+		name      string
+		host      string
+		matches   []string
+		unmatched []string
+	}{
+		{
+			name:      "exact host",
+			host:      "a.example.com",
+			matches:   []string{"a.example.com", "a.example.com:443"},
+			unmatched: []string{"a.example.com.evil.com", "aa.example.com", "a-example.com"},
+		},
+		{
+			name:      "wildcard host",
+			host:      "*.example.com",
+			matches:   []string{"a.example.com", "a.b.example.com:8443"},
+			unmatched: []string{"example.com", "a.example.com.evil.com"},
+		},
+		{
+			name:    "any host",
+			host:    "",
+			matches: []string{"a.example.com", "example.com:80"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route := generateDelegateRoute(DefaultIdentity(), testIngress("web", test.host, "/", "web", http80, nil), vs)
+
+			if route.Name != DefaultIdentity().delegateRouteName("app", "web") || route.Delegate.Name != vs.Name || route.Delegate.Namespace != vs.Namespace {
+				t.Errorf("unexpected route %v", route)
+			}
+
+			if len(route.Match) != 1 {
+				t.Fatalf("expected 1 match, got %d", len(route.Match))
+			}
+
+			authority := regexp.MustCompile(route.Match[0].Authority.GetRegex())
+			for _, host := range test.matches {
+				if !authority.MatchString(host) {
+					t.Errorf("expected %q to match %q", authority, host)
+				}
+			}
+			for _, host := range test.unmatched {
+				if authority.MatchString(host) {
+					t.Errorf("expected %q not to match %q", authority, host)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateRootRoutes(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+
+	ingresses := map[string]*networkingv1.Ingress{
+		"root":     testIngress("root", "a.example.com", "/", "web", http80, nil),
+		"api":      testIngress("api", "a.example.com", "/api", "api", http80, nil),
+		"wildcard": testIngress("wildcard", "*.example.com", "/api/v2", "web", http80, nil),
+		"any":      testIngress("any", "", "/", "web", http80, nil),
+	}
+	priority := func(routeName string) delegateRoutePriority {
+		for name, ingress := range ingresses {
+			if routeName == DefaultIdentity().delegateRouteName("app", name) {
+				return ingressRoutePriority(ingress)
+			}
+		}
+		return delegateRoutePriority{}
+	}
+
+	managed := func(name string) *v1beta1.HTTPRoute {
+		return &v1beta1.HTTPRoute{Name: DefaultIdentity().delegateRouteName("app", name), Delegate: &v1beta1.Delegate{Name: name + "-vs", Namespace: "app"}}
+	}
+	catchAll := &v1beta1.HTTPRoute{Name: "catch-all"}
+
+	tests := []struct {
+		name     string
+		existing []*v1beta1.HTTPRoute
+		ingress  string
+		route    *v1beta1.HTTPRoute
+		want     []string
+	}{
+		{
+			name:     "added to an empty root",
+			existing: []*v1beta1.HTTPRoute{},
+			ingress:  "root",
+			route:    managed("root"),
+			want:     []string{DefaultIdentity().delegateRouteName("app", "root")},
+		},
+		{
+			name:     "added ahead of the unmanaged routes",
+			existing: []*v1beta1.HTTPRoute{catchAll},
+			ingress:  "root",
+			route:    managed("root"),
+			want:     []string{DefaultIdentity().delegateRouteName("app", "root"), "catch-all"},
+		},
+		{
+			name:     "longer paths of the same host first",
+			existing: []*v1beta1.HTTPRoute{managed("root"), catchAll},
+			ingress:  "api",
+			route:    managed("api"),
+			want:     []string{DefaultIdentity().delegateRouteName("app", "api"), DefaultIdentity().delegateRouteName("app", "root"), "catch-all"},
+		},
+		{
+			name:     "exact hosts before wildcard hosts",
+			existing: []*v1beta1.HTTPRoute{managed("any"), managed("wildcard")},
+			ingress:  "root",
+			route:    managed("root"),
+			want:     []string{DefaultIdentity().delegateRouteName("app", "root"), DefaultIdentity().delegateRouteName("app", "wildcard"), DefaultIdentity().delegateRouteName("app", "any")},
+		},
+		{
+			name:     "removed",
+			existing: []*v1beta1.HTTPRoute{managed("api"), managed("root"), catchAll},
+			ingress:  "api",
+			want:     []string{DefaultIdentity().delegateRouteName("app", "root"), "catch-all"},
+		},
+		{
+			name:     "route of another controller left in place",
+			existing: []*v1beta1.HTTPRoute{catchAll, {Name: "other:app/web", Delegate: &v1beta1.Delegate{Name: "web-vs"}}},
+			ingress:  "root",
+			route:    managed("root"),
+			want:     []string{DefaultIdentity().delegateRouteName("app", "root"), "catch-all", "other:app/web"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routes := generateRootRoutes(DefaultIdentity(), test.existing, "app", test.ingress, test.route, priority)
+
+			names := []string{}
+			for _, route := range routes {
+				names = append(names, route.Name)
+			}
+
+			if diff := cmp.Diff(test.want, names); diff != "" {
+				t.Errorf("unexpected routes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
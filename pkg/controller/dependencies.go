@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+
+	istionetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// The translation of an Ingress depends on its Gateways (their ports and HTTPS redirects),
+// its backend Services (their named ports and ExternalNames), its IngressClass and,
+// for its rate limit, the EnvoyFilters of the other Ingresses.
+// Changes to these re-enqueue the Ingresses depending on them, so that they are reconciled
+// as they happen instead of on the next resync, which is paced by the resync queue.
+// This also retries the terminal errors, such as an invalid export, which are not requeued.
+
+// objectFromEvent returns the object of an informer event, recovering deleted objects from their tombstone.
+func objectFromEvent(obj interface{}) (metav1.Object, bool) {
+	if object, ok := obj.(metav1.Object); ok {
+		return object, true
+	}
+
+	tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("error decoding object, invalid type"))
+		return nil, false
+	}
+
+	object, ok := tombstone.Obj.(metav1.Object)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("error decoding object tombstone, invalid type"))
+		return nil, false
+	}
+
+	return object, true
+}
+
+// dependencyEventHandler returns the event handlers of a dependency of the Ingresses,
+// calling enqueue on additions, deletions and updates for which changed returns true.
+func dependencyEventHandler(enqueue func(metav1.Object), changed func(old, new interface{}) bool) cache.ResourceEventHandler {
+	handle := func(obj interface{}) {
+		if object, ok := objectFromEvent(obj); ok {
+			enqueue(object)
+		}
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: handle,
+		UpdateFunc: func(old, new interface{}) {
+			if old.(metav1.Object).GetResourceVersion() == new.(metav1.Object).GetResourceVersion() || !changed(old, new) {
+				return
+			}
+			handle(new)
+		},
+		DeleteFunc: handle,
+	}
+}
+
+// gatewaySpecChanged determines if the spec of a Gateway changed. Gateways are custom resources,
+// whose generation is incremented on changes to the spec, and may be watched as unstructured objects.
+func gatewaySpecChanged(old, new interface{}) bool {
+	return old.(metav1.Object).GetGeneration() != new.(metav1.Object).GetGeneration()
+}
+
+// serviceSpecChanged determines if the spec of a Service changed.
+// Changes to the status of the Services of the gateways are picked up by the resyncs.
+func serviceSpecChanged(old, new interface{}) bool {
+	return !reflect.DeepEqual(old.(*corev1.Service).Spec, new.(*corev1.Service).Spec)
+}
+
+// ingressClassSpecChanged determines if the spec of an IngressClass changed.
+func ingressClassSpecChanged(old, new interface{}) bool {
+	return !reflect.DeepEqual(old.(*networkingv1.IngressClass).Spec, new.(*networkingv1.IngressClass).Spec)
+}
+
+// enqueueIngressesForGateway enqueues the Ingresses attached to the Gateway.
+// In the delegate output mode, all the Ingresses are served on the gateways of the root VirtualService.
+func (c *Controller) enqueueIngressesForGateway(gateway metav1.Object) {
+	c.configLock.RLock()
+	defer c.configLock.RUnlock()
+
+	delegate := c.rootVirtualService != ""
+	c.enqueueIngressesMatching("gateway", gateway, func(ingress *networkingv1.Ingress) bool {
+		if delegate {
+			return true
+		}
+
+		for _, name := range c.getGatewayNamesForIngress(c.withAnnotationDefaults(ingress)) {
+			if name == fmt.Sprintf("%s/%s", gateway.GetNamespace(), gateway.GetName()) ||
+				(name == gateway.GetName() && ingress.Namespace == gateway.GetNamespace()) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// enqueueIngressesForService enqueues the Ingresses of the namespace of the Service using it as a backend.
+func (c *Controller) enqueueIngressesForService(service metav1.Object) {
+	ingresses, err := c.ingressesLister.Ingresses(service.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "error listing the ingresses of the service", "service", klog.KObj(service))
+		return
+	}
+
+	for _, ingress := range ingresses {
+		if ingressUsesService(ingress, service.GetName()) {
+			klog.V(4).InfoS("backend service changed, enqueuing ingress", "service", klog.KObj(service), "ingress", klog.KObj(ingress))
+			c.enqueueIngress(ingress)
+		}
+	}
+}
+
+// ingressUsesService determines if the Service of the namespace of the Ingress is one of its backends.
+func ingressUsesService(ingress *networkingv1.Ingress, name string) bool {
+	if backend := ingress.Spec.DefaultBackend; backend != nil && backend.Service != nil && backend.Service.Name == name {
+		return true
+	}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil && path.Backend.Service.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// enqueueIngressesForIngressClass enqueues the Ingresses referencing the IngressClass.
+func (c *Controller) enqueueIngressesForIngressClass(ingressClass metav1.Object) {
+	c.enqueueIngressesMatching("ingressClass", ingressClass, func(ingress *networkingv1.Ingress) bool {
+		return ingress.Spec.IngressClassName != nil && *ingress.Spec.IngressClassName == ingressClass.GetName()
+	})
+}
+
+// envoyFilterSpecChanged determines if the spec of an EnvoyFilter changed.
+func envoyFilterSpecChanged(old, new interface{}) bool {
+	return !reflect.DeepEqual(old.(*istionetworkingv1alpha3.EnvoyFilter).Spec, new.(*istionetworkingv1alpha3.EnvoyFilter).Spec)
+}
+
+// enqueueIngressesForRateLimitFilter enqueues the Ingresses with a rate limit when an EnvoyFilter
+// of the controller changes: the virtual hosts it no longer configures may be claimed by the other
+// Ingresses, and the shared filter of the gateway workloads may have to be recreated.
+func (c *Controller) enqueueIngressesForRateLimitFilter(filter metav1.Object) {
+	if !c.identity.isManagedByController(filter.GetLabels()) {
+		return
+	}
+
+	c.configLock.RLock()
+	defer c.configLock.RUnlock()
+
+	c.enqueueIngressesMatching("envoyFilter", filter, func(ingress *networkingv1.Ingress) bool {
+		_, ok := c.withAnnotationDefaults(ingress).Annotations[c.identity.key(RateLimitRequestsAnnotation)]
+		return ok
+	})
+}
+
+// enqueueIngressesMatching enqueues the Ingresses for which matches returns true,
+// logging the dependency which caused them to be enqueued.
+func (c *Controller) enqueueIngressesMatching(kind string, dependency metav1.Object, matches func(*networkingv1.Ingress) bool) {
+	ingresses, err := c.ingressesLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "error listing the ingresses", kind, klog.KObj(dependency))
+		return
+	}
+
+	for _, ingress := range ingresses {
+		if matches(ingress) {
+			klog.V(4).InfoS("dependency changed, enqueuing ingress", kind, klog.KObj(dependency), "ingress", klog.KObj(ingress))
+			c.enqueueIngress(ingress)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestEnqueueIngressesForDependencies(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+	className := "istio"
+
+	byClass := testIngress("by-class", "c.example.com", "/", "other", http80, nil)
+	delete(byClass.Annotations, IngressClassAnnotation)
+	byClass.Spec.IngressClassName = &className
+
+	tests := []struct {
+		name      string
+		enqueue   func(c *Controller)
+		rootVS    string
+		ingresses []string
+	}{
+		{
+			name:      "default gateway",
+			enqueue:   func(c *Controller) { c.enqueueIngressesForGateway(testGateway("istio-system", "ingressgateway")) },
+			ingresses: []string{"app/by-class", "app/web"},
+		},
+		{
+			name:      "gateway of the annotation in the namespace of the ingress",
+			enqueue:   func(c *Controller) { c.enqueueIngressesForGateway(testGateway("app", "local")) },
+			ingresses: []string{"app/local"},
+		},
+		{
+			name:      "unused gateway",
+			enqueue:   func(c *Controller) { c.enqueueIngressesForGateway(testGateway("other", "ingressgateway")) },
+			ingresses: []string{},
+		},
+		{
+			name:      "all ingresses in the delegate output mode",
+			rootVS:    "istio-system/root",
+			enqueue:   func(c *Controller) { c.enqueueIngressesForGateway(testGateway("other", "ingressgateway")) },
+			ingresses: []string{"app/by-class", "app/local", "app/web"},
+		},
+		{
+			name: "backend service",
+			enqueue: func(c *Controller) {
+				c.enqueueIngressesForService(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"}})
+			},
+			ingresses: []string{"app/local", "app/web"},
+		},
+		{
+			name: "service of the same name in another namespace",
+			enqueue: func(c *Controller) {
+				c.enqueueIngressesForService(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "other"}})
+			},
+			ingresses: []string{},
+		},
+		{
+			name: "ingress class",
+			enqueue: func(c *Controller) {
+				c.enqueueIngressesForIngressClass(&networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{Name: "istio"}})
+			},
+			ingresses: []string{"app/by-class"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestController(t, Config{DefaultGateway: "istio-system/ingressgateway", IngressClass: "istio"},
+				testIngress("web", "a.example.com", "/", "web", http80, nil),
+				testIngress("local", "b.example.com", "/", "web", http80, map[string]string{GatewaysAnnotation: "local"}),
+				byClass,
+			)
+			c.rootVirtualService = test.rootVS
+			c.workqueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer c.workqueue.ShutDown()
+
+			test.enqueue(c)
+
+			keys := []string{}
+			for c.workqueue.Len() > 0 {
+				key, _ := c.workqueue.Get()
+				keys = append(keys, key.(string))
+				c.workqueue.Done(key)
+			}
+			sort.Strings(keys)
+
+			if !stringArrayEquals(keys, test.ingresses) {
+				t.Errorf("expected %v to be enqueued, got %v", test.ingresses, keys)
+			}
+		})
+	}
+}
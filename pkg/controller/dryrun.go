@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/klog/v2"
+)
+
+// Kinds of the changes reported in the dry-run mode.
+const (
+	dryRunKindVirtualService = "VirtualService"
+	dryRunKindIngressStatus  = "IngressStatus"
+)
+
+// dryRunChange is a change the controller would make to the cluster outside of the dry-run mode.
+type dryRunChange struct {
+	Ingress   string    `json:"ingress"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name,omitempty"`
+	Operation string    `json:"operation"`
+	Diff      string    `json:"diff"`
+	Time      time.Time `json:"time"`
+}
+
+// dryRunChanges tracks the pending changes of the Ingresses in the dry-run mode.
+// The changes of an Ingress are replaced each time the Ingress is reconciled.
+type dryRunChanges struct {
+	lock    sync.Mutex
+	changes map[string][]*dryRunChange
+}
+
+// set replaces the changes of the given kind for the Ingress.
+func (d *dryRunChanges) set(key, kind string, changes []*dryRunChange) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.changes == nil {
+		d.changes = map[string][]*dryRunChange{}
+	}
+
+	kept := []*dryRunChange{}
+	for _, change := range d.changes[key] {
+		if kind != "" && change.Kind != kind {
+			kept = append(kept, change)
+		}
+	}
+	kept = append(kept, changes...)
+
+	if len(kept) == 0 {
+		delete(d.changes, key)
+	} else {
+		d.changes[key] = kept
+	}
+
+	dryRunChangesGauge.Reset()
+	for _, changes := range d.changes {
+		for _, change := range changes {
+			dryRunChangesGauge.WithLabelValues(change.Kind, change.Operation).Inc()
+		}
+	}
+}
+
+// list returns the pending changes, by Ingress.
+func (d *dryRunChanges) list() []*dryRunChange {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	list := []*dryRunChange{}
+	for _, changes := range d.changes {
+		list = append(list, changes...)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Ingress < list[j].Ingress
+	})
+
+	return list
+}
+
+// newDryRunChange returns a change the controller would make for the Ingress and logs it.
+func (c *Controller) newDryRunChange(ingress *networkingv1.Ingress, kind, operation, name, diff string) *dryRunChange {
+	klog.InfoS("dry-run: change not applied", c.logValues(ingress.Namespace, ingress.Name, "kind", kind, "operation", operation, "object", name, "diff", diff)...)
+
+	return &dryRunChange{
+		Ingress:   fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name),
+		Kind:      kind,
+		Name:      name,
+		Operation: operation,
+		Diff:      diff,
+		Time:      time.Now(),
+	}
+}
+
+// setDryRunStatusChange records the change to the status of the Ingress, if any.
+func (c *Controller) setDryRunStatusChange(ingress *networkingv1.Ingress, desired corev1.LoadBalancerStatus) {
+	changes := []*dryRunChange{}
+	if diff := cmp.Diff(ingress.Status.LoadBalancer, desired); diff != "" {
+		changes = append(changes, c.newDryRunChange(ingress, dryRunKindIngressStatus, "update", ingress.Name, diff))
+	}
+
+	c.dryRunChanges.set(fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name), dryRunKindIngressStatus, changes)
+}
+
+// DryRunHandler returns the handler listing the changes the controller would make
+// to the cluster outside of the dry-run mode, served under the /debug/dry-run path.
+func (c *Controller) DryRunHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.dryRun {
+			http.Error(w, "the controller is not running in the dry-run mode", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(c.dryRunChanges.list()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// dryRunVirtualService records the change to the VirtualService of the Ingress, if any,
+// and returns the VirtualService as it would be once applied.
+func (c *Controller) dryRunVirtualService(ingress *networkingv1.Ingress, live, desired *istionetworkingv1beta1.VirtualService, changed bool) (*istionetworkingv1beta1.VirtualService, error) {
+	applied := desired.DeepCopy()
+	if live != nil {
+		// The metadata of other field managers is preserved by the server
+		applied.Name = live.Name
+		applied.Labels = mergeMaps(live.Labels, desired.Labels)
+		applied.Annotations = mergeMaps(live.Annotations, desired.Annotations)
+		for _, ref := range live.OwnerReferences {
+			if ref.UID != ingress.UID {
+				applied.OwnerReferences = append(applied.OwnerReferences, ref)
+			}
+		}
+	}
+
+	changes := []*dryRunChange{}
+	if changed {
+		operation := "update"
+		if live == nil {
+			operation = "create"
+		}
+
+		diff, err := diffVirtualServices(live, applied)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c.newDryRunChange(ingress, dryRunKindVirtualService, operation, applied.Name, diff))
+	}
+	c.dryRunChanges.set(fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name), dryRunKindVirtualService, changes)
+
+	return applied, nil
+}
+
+// dryRunRemoveVirtualServices records the removal of the VirtualServices of the Ingress.
+func (c *Controller) dryRunRemoveVirtualServices(ingress *networkingv1.Ingress, vss []*istionetworkingv1beta1.VirtualService) error {
+	changes := []*dryRunChange{}
+	for _, vs := range vss {
+		diff, err := diffVirtualServices(vs, nil)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, c.newDryRunChange(ingress, dryRunKindVirtualService, "delete", vs.Name, diff))
+	}
+	c.dryRunChanges.set(fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name), dryRunKindVirtualService, changes)
+
+	return nil
+}
+
+// mergeMaps returns the entries of the maps, the later maps taking precedence.
+func mergeMaps(maps ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, m := range maps {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
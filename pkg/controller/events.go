@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Reasons of the Events recorded on Ingresses.
+// These are stable and can be relied upon for alerting.
+const (
+	ReasonVirtualServiceCreated = "VirtualServiceCreated"
+	ReasonVirtualServiceUpdated = "VirtualServiceUpdated"
+	ReasonVirtualServiceDeleted = "VirtualServiceDeleted"
+	ReasonStatusUpdated         = "StatusUpdated"
+
+	ReasonAdoptVirtualServiceDryRun = "AdoptVirtualServiceDryRun"
+	ReasonAdoptVirtualServiceFailed = "AdoptVirtualServiceFailed"
+
+	ReasonUnknownGateway        = "UnknownGateway"
+	ReasonMissingBackendService = "MissingBackendService"
+	ReasonUnresolvablePort      = "UnresolvablePort"
+	ReasonInvalidAnnotation     = "InvalidAnnotation"
+	ReasonMissingHTTPRules      = "MissingHTTPRules"
+	ReasonRateLimitConflict     = "RateLimitConflict"
+	ReasonUnsupportedAnnotation = "UnsupportedAnnotation"
+	ReasonExternalNameConflict  = "ExternalNameConflict"
+)
+
+// reconcileError is an error in the configuration of an Ingress,
+// which is reported to its owner through a Warning Event on the Ingress.
+// Terminal errors are not retried until the Ingress or its dependencies change.
+type reconcileError struct {
+	reason   string
+	err      error
+	terminal bool
+}
+
+func (e *reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reconcileError) Unwrap() error {
+	return e.err
+}
+
+// newReconcileError returns an error reported with the reason on the Ingress.
+func newReconcileError(reason string, format string, a ...interface{}) error {
+	return &reconcileError{reason: reason, err: fmt.Errorf(format, a...)}
+}
+
+// newTerminalReconcileError returns an error reported with the reason on the Ingress,
+// which cannot be resolved by retrying the reconcile.
+func newTerminalReconcileError(reason string, format string, a ...interface{}) error {
+	return &reconcileError{reason: reason, err: fmt.Errorf(format, a...), terminal: true}
+}
+
+// isTerminalReconcileError determines if the error is a terminal reconcileError.
+func isTerminalReconcileError(err error) bool {
+	var rerr *reconcileError
+	return errors.As(err, &rerr) && rerr.terminal
+}
+
+// recordReconcileError records a Warning Event on the Ingress if the error is a reconcileError.
+func (c *Controller) recordReconcileError(ingress *networkingv1.Ingress, err error) {
+	var rerr *reconcileError
+	if errors.As(err, &rerr) {
+		c.recorder.Event(ingress, corev1.EventTypeWarning, rerr.reason, rerr.Error())
+	}
+}
+
+// reportedWarnings tracks the warnings recorded on the Ingresses by reason. The warnings which persist
+// across reconciles, such as an unknown gateway, are only recorded when they appear.
+type reportedWarnings struct {
+	lock     sync.Mutex
+	warnings map[string]map[string][]string
+}
+
+// update replaces the warnings of the Ingress for the reason and returns those which were not reported before.
+func (w *reportedWarnings) update(key, reason string, messages []string) []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.warnings == nil {
+		w.warnings = map[string]map[string][]string{}
+	}
+	if w.warnings[key] == nil {
+		w.warnings[key] = map[string][]string{}
+	}
+
+	added := []string{}
+	for _, message := range messages {
+		if !stringInArray(message, w.warnings[key][reason]) {
+			added = append(added, message)
+		}
+	}
+
+	if len(messages) == 0 {
+		delete(w.warnings[key], reason)
+	} else {
+		w.warnings[key][reason] = messages
+	}
+
+	return added
+}
+
+// forget forgets the warnings of the Ingress, which no longer exists.
+func (w *reportedWarnings) forget(key string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.warnings, key)
+}
+
+// recordWarnings records a Warning Event on the Ingress for each of the current warnings of the reason
+// which was not recorded by the previous reconcile.
+func (c *Controller) recordWarnings(ingress *networkingv1.Ingress, reason string, messages []string) {
+	key, err := cache.MetaNamespaceKeyFunc(ingress)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	for _, message := range c.reportedWarnings.update(key, reason, messages) {
+		c.recorder.Event(ingress, corev1.EventTypeWarning, reason, message)
+	}
+}
+
+// recordUnknownGateways records a Warning Event on the Ingress for each of the gateways
+// which were not found. Unknown gateways are skipped by getGatewaysByName.
+func (c *Controller) recordUnknownGateways(ingress *networkingv1.Ingress, gatewayNames []string, gateways []*istionetworkingv1beta1.Gateway, currentNamespace string) {
+	messages := []string{}
+	for _, gatewayName := range getUnknownGateways(gatewayNames, gateways, currentNamespace) {
+		klog.InfoS("gateway does not exist", c.logValues(ingress.Namespace, ingress.Name, "gateway", gatewayName)...)
+		messages = append(messages, fmt.Sprintf("Gateway %q does not exist", gatewayName))
+	}
+
+	c.recordWarnings(ingress, ReasonUnknownGateway, messages)
+}
+
+// getUnknownGateways returns the names of the gateways which are not among the gateways found.
+func getUnknownGateways(gatewayNames []string, gateways []*istionetworkingv1beta1.Gateway, currentNamespace string) []string {
+	unknown := []string{}
+
+	for _, gatewayName := range gatewayNames {
+		if gatewayName == "mesh" {
+			continue
+		}
+
+		found := false
+		for _, gateway := range gateways {
+			if gatewayName == fmt.Sprintf("%s/%s", gateway.Namespace, gateway.Name) || (gatewayName == gateway.Name && gateway.Namespace == currentNamespace) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			unknown = append(unknown, gatewayName)
+		}
+	}
+
+	return unknown
+}
+
+// recordMissingBackendServices records a Warning Event on the Ingress for each of the
+// Services referenced by its backends which do not exist.
+func (c *Controller) recordMissingBackendServices(ingress *networkingv1.Ingress) {
+	messages := []string{}
+	for _, name := range c.getMissingBackendServices(ingress) {
+		messages = append(messages, fmt.Sprintf("Backend service %q does not exist", name))
+	}
+
+	c.recordWarnings(ingress, ReasonMissingBackendService, messages)
+}
+
+// getMissingBackendServices returns the names of the Services referenced by the backends
+// of the Ingress which do not exist.
+func (c *Controller) getMissingBackendServices(ingress *networkingv1.Ingress) []string {
+	missing := []string{}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil || stringInArray(path.Backend.Service.Name, missing) {
+				continue
+			}
+
+			_, err := c.servicesLister.Services(ingress.Namespace).Get(path.Backend.Service.Name)
+			if apierrors.IsNotFound(err) {
+				missing = append(missing, path.Backend.Service.Name)
+			}
+		}
+	}
+
+	return missing
+}
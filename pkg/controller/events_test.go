@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRecordWarnings(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+
+	tests := []struct {
+		name       string
+		reconciles [][]string
+		want       []string
+	}{
+		{
+			name:       "persisting warning",
+			reconciles: [][]string{{"Gateway \"a\" does not exist"}, {"Gateway \"a\" does not exist"}},
+			want:       []string{"Warning UnknownGateway Gateway \"a\" does not exist"},
+		},
+		{
+			name:       "added warning",
+			reconciles: [][]string{{"Gateway \"a\" does not exist"}, {"Gateway \"a\" does not exist", "Gateway \"b\" does not exist"}},
+			want:       []string{"Warning UnknownGateway Gateway \"a\" does not exist", "Warning UnknownGateway Gateway \"b\" does not exist"},
+		},
+		{
+			name:       "warning resolved and back",
+			reconciles: [][]string{{"Gateway \"a\" does not exist"}, nil, {"Gateway \"a\" does not exist"}},
+			want:       []string{"Warning UnknownGateway Gateway \"a\" does not exist", "Warning UnknownGateway Gateway \"a\" does not exist"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestController(t, Config{})
+			ingress := testIngress("web", "a.example.com", "/", "web", http80, nil)
+
+			for _, messages := range test.reconciles {
+				c.recordWarnings(ingress, ReasonUnknownGateway, messages)
+			}
+
+			events := []string{}
+			recorder := c.recorder.(*record.FakeRecorder)
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+
+			if diff := cmp.Diff(test.want, events); diff != "" {
+				t.Errorf("unexpected events (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetGatewaysByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		invalid bool
+	}{
+		{
+			name:  "name in the namespace of the ingress",
+			names: []string{"gateway"},
+			want:  []string{"app/gateway"},
+		},
+		{
+			name:  "namespaced name",
+			names: []string{"istio-system/ingressgateway"},
+			want:  []string{"istio-system/ingressgateway"},
+		},
+		{
+			name:  "unknown gateway skipped",
+			names: []string{"istio-system/unknown", "mesh"},
+			want:  []string{},
+		},
+		{
+			name:    "too many parts",
+			names:   []string{"a/b/c"},
+			invalid: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestController(t, Config{}, testGateway("istio-system", "ingressgateway"), testGateway("app", "gateway"))
+
+			gateways, err := c.getGatewaysByName(test.names, "app")
+			if test.invalid {
+				var rerr *reconcileError
+				if !errors.As(err, &rerr) || rerr.reason != ReasonInvalidAnnotation {
+					t.Fatalf("expected an %s error, got %v", ReasonInvalidAnnotation, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			names := []string{}
+			for _, gateway := range gateways {
+				names = append(names, gateway.Namespace+"/"+gateway.Name)
+			}
+			if diff := cmp.Diff(test.want, names); diff != "" {
+				t.Errorf("unexpected gateways (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// externalService is an ExternalName Service referenced by an Ingress backend.
+type externalService struct {
+	service *corev1.Service
+	ports   []uint32
+}
+
+// getDestinationHost returns the host to which the traffic for the backend is routed.
+// ExternalName Services are routed to their external name, as the cluster local
+// name of the Service does not resolve in the mesh.
+func (c *Controller) getDestinationHost(namespace string, backend networkingv1.IngressBackend) (string, error) {
+	service, err := c.servicesLister.Services(namespace).Get(backend.Service.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+
+	if service != nil && service.Spec.Type == corev1.ServiceTypeExternalName {
+		return service.Spec.ExternalName, nil
+	}
+
+	return fmt.Sprintf("%s.%s.svc.%s", backend.Service.Name, namespace, c.clusterDomain), nil
+}
+
+// getExternalServicesForIngress returns the ExternalName Services referenced by the backends of the Ingress.
+func (c *Controller) getExternalServicesForIngress(ingress *networkingv1.Ingress) ([]*externalService, error) {
+	services := map[string]*externalService{}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				continue
+			}
+
+			service, err := c.servicesLister.Services(ingress.Namespace).Get(path.Backend.Service.Name)
+			if errors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			if service.Spec.Type != corev1.ServiceTypeExternalName {
+				continue
+			}
+
+			port, err := c.getServicePort(ingress.Namespace, path.Backend)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := services[service.Name]; !ok {
+				services[service.Name] = &externalService{service: service}
+			}
+			if !uint32InArray(port, services[service.Name].ports) {
+				services[service.Name].ports = append(services[service.Name].ports, port)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]*externalService, len(names))
+	for i, name := range names {
+		sort.Slice(services[name].ports, func(a, b int) bool { return services[name].ports[a] < services[name].ports[b] })
+		result[i] = services[name]
+	}
+
+	return result, nil
+}
+
+// handleExternalServicesForIngress synchronizes the ServiceEntries and DestinationRules
+// for the ExternalName Services referenced by the Ingress.
+// If the Ingress is not handled, all of the owned resources are removed.
+func (c *Controller) handleExternalServicesForIngress(ingress *networkingv1.Ingress, handle bool) error {
+	// Left unchanged in the dry-run mode
+	if c.dryRun {
+		return nil
+	}
+
+	desiredServiceEntries := []*istionetworkingv1beta1.ServiceEntry{}
+	desiredDestinationRules := []*istionetworkingv1beta1.DestinationRule{}
+
+	if handle {
+		externalServices, err := c.getExternalServicesForIngress(ingress)
+		if err != nil {
+			return err
+		}
+
+		exportTo := []string{}
+		if len(externalServices) > 0 {
+			exportTo, err = c.getExternalServiceExportTo(ingress)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, es := range externalServices {
+			desiredServiceEntries = append(desiredServiceEntries, generateServiceEntry(c.identity, ingress, es, exportTo))
+
+			if uint32InArray(443, es.ports) {
+				dr := generateDestinationRule(c.identity, ingress.Namespace, es.service.Spec.ExternalName)
+				if !destinationRuleInArray(dr, desiredDestinationRules) {
+					desiredDestinationRules = append(desiredDestinationRules, dr)
+				}
+			}
+		}
+	}
+
+	// ServiceEntries
+	serviceEntries, err := c.serviceEntriesLister.ServiceEntries(ingress.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	existingServiceEntries := []*istionetworkingv1beta1.ServiceEntry{}
+	for _, se := range serviceEntries {
+		if metav1.IsControlledBy(se, ingress) && c.identity.isManagedByController(se.Labels) {
+			existingServiceEntries = append(existingServiceEntries, se)
+		}
+	}
+
+	if err := syncOwnedObjects(c, ingress.Namespace, ingress.Name, c.externalServiceEntries(), existingServiceEntries, desiredServiceEntries); err != nil {
+		return err
+	}
+
+	return c.handleExternalDestinationRulesForIngress(ingress, desiredDestinationRules)
+}
+
+// externalServiceEntries describes the ServiceEntries registering the external names of the Ingresses.
+func (c *Controller) externalServiceEntries() ownedKind[istionetworkingv1beta1.ServiceEntry] {
+	return ownedKind[istionetworkingv1beta1.ServiceEntry]{
+		name:   "service entry",
+		logKey: "serviceEntry",
+		client: c.istioNetworking.ServiceEntries,
+		withSpec: func(current, desired *istionetworkingv1beta1.ServiceEntry) *istionetworkingv1beta1.ServiceEntry {
+			updated := current.DeepCopy()
+			updated.Spec = desired.Spec
+			return updated
+		},
+	}
+}
+
+// handleExternalDestinationRulesForIngress synchronizes the DestinationRules originating TLS to the external names
+// of the Ingress. They are shared by the Ingresses of the namespace routing to the same external name, as Istio
+// does not merge several DestinationRules for a host, and each of these Ingresses is one of their owners.
+// A DestinationRule is deleted once its last Ingress no longer routes to its host.
+func (c *Controller) handleExternalDestinationRulesForIngress(ingress *networkingv1.Ingress, desired []*istionetworkingv1beta1.DestinationRule) error {
+	ctx := context.Background()
+
+	destinationRules, err := c.destinationRulesLister.DestinationRules(ingress.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	for _, edr := range destinationRules {
+		if !c.identity.isManagedByController(edr.Labels) || destinationRuleInArray(edr, desired) {
+			continue
+		}
+
+		// The DestinationRules of previous versions were owned by a single Ingress
+		if metav1.IsControlledBy(edr, ingress) {
+			klog.InfoS("removing destination rule", c.logValues(ingress.Namespace, ingress.Name, "destinationRule", klog.KObj(edr))...)
+			err = c.istioNetworking.DestinationRules(edr.Namespace).Delete(ctx, edr.Name, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+
+		owners := withoutOwnerReference(edr.OwnerReferences, ingress.UID)
+		if len(owners) == len(edr.OwnerReferences) {
+			continue
+		}
+
+		if len(owners) == 0 {
+			klog.InfoS("removing destination rule", c.logValues(ingress.Namespace, ingress.Name, "destinationRule", klog.KObj(edr))...)
+			err = c.istioNetworking.DestinationRules(edr.Namespace).Delete(ctx, edr.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &edr.ResourceVersion}})
+		} else {
+			klog.InfoS("removing ingress from the owners of destination rule", c.logValues(ingress.Namespace, ingress.Name, "destinationRule", klog.KObj(edr))...)
+			updated := edr.DeepCopy()
+			updated.OwnerReferences = owners
+			_, err = c.istioNetworking.DestinationRules(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	owner := ingressOwnerReference(ingress)
+	// Shared by several Ingresses, none of which is its controller
+	owner.Controller = nil
+
+	for _, dr := range desired {
+		current, err := c.destinationRulesLister.DestinationRules(dr.Namespace).Get(dr.Name)
+		if errors.IsNotFound(err) {
+			klog.InfoS("creating destination rule", c.logValues(ingress.Namespace, ingress.Name, "destinationRule", klog.KObj(dr))...)
+			dr.OwnerReferences = []metav1.OwnerReference{owner}
+			_, err = c.istioNetworking.DestinationRules(dr.Namespace).Create(ctx, dr, metav1.CreateOptions{})
+			if err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if !c.identity.isManagedByController(current.Labels) {
+			return newReconcileError(ReasonExternalNameConflict, "destination rule \"%s/%s\" of external name %q is not managed by the controller", current.Namespace, current.Name, dr.Spec.Host)
+		}
+
+		owners := current.OwnerReferences
+		if len(withoutOwnerReference(owners, ingress.UID)) == len(owners) {
+			owners = append(append([]metav1.OwnerReference{}, owners...), owner)
+		}
+
+		if !reflect.DeepEqual(current.OwnerReferences, owners) || !reflect.DeepEqual(current.Labels, dr.Labels) || !reflect.DeepEqual(current.Spec, dr.Spec) {
+			klog.InfoS("updating destination rule", c.logValues(ingress.Namespace, ingress.Name, "destinationRule", klog.KObj(dr))...)
+
+			updated := current.DeepCopy()
+			updated.OwnerReferences = owners
+			updated.Labels = dr.Labels
+			updated.Spec = dr.Spec
+
+			_, err = c.istioNetworking.DestinationRules(dr.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getExternalServiceExportTo returns the namespaces to which the ServiceEntries of the Ingress are exported:
+// its own namespace and those of the workloads of its gateways, which route to the external names.
+func (c *Controller) getExternalServiceExportTo(ingress *networkingv1.Ingress) ([]string, error) {
+	gatewayNames := c.getGatewayNamesForIngress(ingress)
+
+	// The workloads of the Gateway API Gateways of the httproute output run in the namespaces of the Gateways
+	namespaces := []string{}
+	for _, gatewayName := range gatewayNames {
+		if parts := strings.SplitN(gatewayName, "/", 2); len(parts) == 2 && !stringInArray(parts[0], namespaces) {
+			namespaces = append(namespaces, parts[0])
+		}
+	}
+
+	gateways, err := c.getGatewaysByName(gatewayNames, ingress.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, gateway := range gateways {
+		workloadNamespaces, err := c.getWorkloadNamespacesForGateway(gateway)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, namespace := range workloadNamespaces {
+			if !stringInArray(namespace, namespaces) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+	sort.Strings(namespaces)
+
+	exportTo := []string{"."}
+	for _, namespace := range namespaces {
+		if namespace != ingress.Namespace {
+			exportTo = append(exportTo, namespace)
+		}
+	}
+
+	return exportTo, nil
+}
+
+// destinationRuleInArray determines if a DestinationRule with the same name is in the array.
+func destinationRuleInArray(dr *istionetworkingv1beta1.DestinationRule, arr []*istionetworkingv1beta1.DestinationRule) bool {
+	for _, d := range arr {
+		if d.Name == dr.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutOwnerReference returns the owner references without those of the owner.
+func withoutOwnerReference(refs []metav1.OwnerReference, uid types.UID) []metav1.OwnerReference {
+	ret := []metav1.OwnerReference{}
+	for _, ref := range refs {
+		if ref.UID != uid {
+			ret = append(ret, ref)
+		}
+	}
+
+	return ret
+}
+
+// generateServiceEntry generates a ServiceEntry registering the external name
+// of the Service in the namespaces of the exportTo, on the ports used by the Ingress.
+func generateServiceEntry(id *Identity, ingress *networkingv1.Ingress, es *externalService, exportTo []string) *istionetworkingv1beta1.ServiceEntry {
+	se := &istionetworkingv1beta1.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatedName(ingress.Name, es.service.Name),
+			Namespace: ingress.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				ingressOwnerReference(ingress),
+			},
+			Labels: map[string]string{
+				managedByLabel:                 id.managedBy,
+				"app.kubernetes.io/created-by": id.managedBy,
+			},
+		},
+		Spec: v1beta1.ServiceEntry{
+			Hosts:      []string{es.service.Spec.ExternalName},
+			Location:   v1beta1.ServiceEntry_MESH_EXTERNAL,
+			Resolution: v1beta1.ServiceEntry_DNS,
+			ExportTo:   exportTo,
+		},
+	}
+
+	for _, port := range es.ports {
+		protocol := "HTTP"
+		if port == 443 {
+			protocol = "HTTPS"
+		}
+
+		se.Spec.Ports = append(se.Spec.Ports, &v1beta1.Port{
+			Number:   port,
+			Protocol: protocol,
+			Name:     fmt.Sprintf("%s-%d", strings.ToLower(protocol), port),
+		})
+	}
+
+	return se
+}
+
+// generateDestinationRule generates the DestinationRule of the namespace originating TLS
+// to the external name on port 443. Its owners are set by handleExternalDestinationRulesForIngress.
+func generateDestinationRule(id *Identity, namespace, externalName string) *istionetworkingv1beta1.DestinationRule {
+	return &istionetworkingv1beta1.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatedName(externalName, "tls"),
+			Namespace: namespace,
+			Labels: map[string]string{
+				managedByLabel:                 id.managedBy,
+				"app.kubernetes.io/created-by": id.managedBy,
+			},
+		},
+		Spec: v1beta1.DestinationRule{
+			Host: externalName,
+			TrafficPolicy: &v1beta1.TrafficPolicy{
+				PortLevelSettings: []*v1beta1.TrafficPolicy_PortTrafficPolicy{
+					{
+						Port: &v1beta1.PortSelector{
+							Number: 443,
+						},
+						Tls: &v1beta1.ClientTLSSettings{
+							Mode: v1beta1.ClientTLSSettings_SIMPLE,
+							Sni:  externalName,
+						},
+					},
+				},
+			},
+		},
+	}
+}
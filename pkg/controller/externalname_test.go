@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestHandleExternalDestinationRulesForIngress(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Number: 80}
+	ingress := testIngress("web", "a.example.com", "/", "external", http80, nil)
+	ingress.UID = "web-uid"
+	other := testIngress("other", "b.example.com", "/", "external", http80, nil)
+	other.UID = "other-uid"
+
+	owner := func(ingress *networkingv1.Ingress) metav1.OwnerReference {
+		ref := ingressOwnerReference(ingress)
+		ref.Controller = nil
+		return ref
+	}
+	shared := func(owners ...*networkingv1.Ingress) *istionetworkingv1beta1.DestinationRule {
+		dr := generateDestinationRule(DefaultIdentity(), "app", "api.example.org")
+		for _, ingress := range owners {
+			dr.OwnerReferences = append(dr.OwnerReferences, owner(ingress))
+		}
+		return dr
+	}
+	legacy := generateDestinationRule(DefaultIdentity(), "app", "api.example.org")
+	legacy.Name = generatedName("web", "external")
+	legacy.OwnerReferences = []metav1.OwnerReference{ingressOwnerReference(ingress)}
+
+	tests := []struct {
+		name     string
+		existing []*istionetworkingv1beta1.DestinationRule
+		desired  bool
+		owners   map[string][]types.UID
+	}{
+		{
+			name:    "created",
+			desired: true,
+			owners:  map[string][]types.UID{shared().Name: {ingress.UID}},
+		},
+		{
+			name:     "shared with another ingress",
+			existing: []*istionetworkingv1beta1.DestinationRule{shared(other)},
+			desired:  true,
+			owners:   map[string][]types.UID{shared().Name: {other.UID, ingress.UID}},
+		},
+		{
+			name:     "released to another ingress",
+			existing: []*istionetworkingv1beta1.DestinationRule{shared(other, ingress)},
+			owners:   map[string][]types.UID{shared().Name: {other.UID}},
+		},
+		{
+			name:     "deleted with its last ingress",
+			existing: []*istionetworkingv1beta1.DestinationRule{shared(ingress)},
+			owners:   map[string][]types.UID{},
+		},
+		{
+			name:     "owned by a single ingress in a previous version",
+			existing: []*istionetworkingv1beta1.DestinationRule{legacy},
+			desired:  true,
+			owners:   map[string][]types.UID{shared().Name: {ingress.UID}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{}
+			for _, dr := range test.existing {
+				objects = append(objects, dr.DeepCopy())
+			}
+
+			c := newTestController(t, Config{}, objects...)
+			client := istiofake.NewSimpleClientset(objects...)
+			c.istioNetworking = &istioNetworking{version: IstioNetworkingV1beta1, istioclientset: client}
+
+			desired := []*istionetworkingv1beta1.DestinationRule{}
+			if test.desired {
+				desired = append(desired, generateDestinationRule(DefaultIdentity(), "app", "api.example.org"))
+			}
+
+			if err := c.handleExternalDestinationRulesForIngress(ingress, desired); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			list, err := client.NetworkingV1beta1().DestinationRules("app").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			owners := map[string][]types.UID{}
+			for _, dr := range list.Items {
+				owners[dr.Name] = []types.UID{}
+				for _, ref := range dr.OwnerReferences {
+					if ref.Controller != nil && *ref.Controller {
+						t.Errorf("expected no controller of the shared destination rule %q", dr.Name)
+					}
+					owners[dr.Name] = append(owners[dr.Name], ref.UID)
+				}
+			}
+
+			if diff := cmp.Diff(test.owners, owners); diff != "" {
+				t.Errorf("unexpected owners (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetExternalServiceExportTo(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Number: 80}
+	gatewayService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-ingressgateway", Namespace: "ingress", Labels: map[string]string{"istio": "ingressgateway"}},
+	}
+
+	tests := []struct {
+		name     string
+		gateways string
+		want     []string
+	}{
+		{
+			name:     "workloads of the gateway in another namespace",
+			gateways: "istio-system/ingressgateway",
+			want:     []string{".", "ingress", "istio-system"},
+		},
+		{
+			name:     "gateway in the namespace of the ingress",
+			gateways: "app/gateway",
+			want:     []string{".", "ingress"},
+		},
+		{
+			name:     "gateway api gateway",
+			gateways: "gateways/public",
+			want:     []string{".", "gateways"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestController(t, Config{}, gatewayService, testGateway("istio-system", "ingressgateway"), testGateway("app", "gateway"))
+			ingress := testIngress("web", "a.example.com", "/", "external", http80, map[string]string{GatewaysAnnotation: test.gateways})
+
+			exportTo, err := c.getExternalServiceExportTo(ingress)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(test.want, exportTo); diff != "" {
+				t.Errorf("unexpected exportTo (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -9,10 +9,11 @@ import (
 
 	"istio.io/api/networking/v1beta1"
 	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 )
 
 var (
@@ -22,18 +23,20 @@ var (
 	IgnoreAnnotation = "ingress.statcan.gc.ca/ignore"
 	// Comma seperated list of Gateways in <namespace>/<name> format
 	GatewaysAnnotation = "ingress.statcan.gc.ca/gateways"
-	// The value verified in IngressClass.spec.controller
-	IngressIstioController = "ingress.statcan.gc.ca/ingress-istio-controller"
+	// Comma seperated list of the namespaces to which the VirtualService is exported ("." for the namespace of the Ingress, "*" for all namespaces)
+	ExportToAnnotation = "ingress.statcan.gc.ca/export-to"
 )
 
 func (c *Controller) findExistingVirtualServiceForIngress(ingress *networkingv1.Ingress) (*istionetworkingv1beta1.VirtualService, error) {
-	vss, err := c.virtualServicesListers.VirtualServices(ingress.Namespace).List(labels.Everything())
+	vss, err := c.findExistingVirtualServicesForIngress(ingress)
 	if err != nil {
 		return nil, err
 	}
 
+	adoptName, _ := getAdoptVirtualServiceName(c.identity, ingress)
 	for _, vs := range vss {
-		if metav1.IsControlledBy(vs, ingress) {
+		// With deterministic names, VirtualServices of other names are replaced unless adopted
+		if !c.deterministicNames || vs.Name == virtualServiceName(ingress.Name) || vs.Name == adoptName {
 			return vs, nil
 		}
 	}
@@ -50,17 +53,219 @@ func (c *Controller) handleVirtualServiceForIngress(ingress *networkingv1.Ingres
 	if err != nil {
 		return nil, err
 	}
+	if vs != nil {
+		c.reconcileLogs.setVirtualService(fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name), vs.Name)
+	}
+
+	handle, err := c.shouldHandleIngress(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	if !handle {
+		if !c.dryRun {
+			err := c.removeClientCertificateGatewaysForIngress(ingress.Namespace, ingress.Name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// VirtualServices already exist, so let's delete them
+		vss, err := c.findExistingVirtualServicesForIngress(ingress)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.dryRun {
+			return nil, c.dryRunRemoveVirtualServices(ingress, vss)
+		}
+
+		for _, vs := range vss {
+			klog.InfoS("removing owned virtualservice", c.logValues(ingress.Namespace, ingress.Name, "virtualService", vs.Name)...)
+			err := c.istioNetworking.VirtualServices(vs.Namespace).Delete(ctx, vs.Name, metav1.DeleteOptions{})
+			if err != nil {
+				return nil, err
+			}
+			virtualServiceOperationsTotal.WithLabelValues("delete").Inc()
+			c.recorder.Eventf(ingress, corev1.EventTypeNormal, ReasonVirtualServiceDeleted, "Deleted virtualservice %q", vs.Name)
+		}
+
+		if len(vss) > 0 {
+			return nil, nil
+		}
+
+		klog.InfoS("skipping ingress", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, nil
+	}
+
+	var gateways []string
+	if c.rootVirtualService != "" {
+		// Delegates are served on the gateways of the root VirtualService
+		gateways, err = c.getRootGatewayNames()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Identify the gateway to attach the ingress to
+		gateways = c.getGatewayNamesForIngress(ingress)
+
+		// Attach to dedicated gateways if client certificates are required
+		if c.dryRun {
+			_, gateways, err = c.getClientCertificateGatewaysForIngress(ingress, gateways)
+		} else {
+			gateways, err = c.handleClientCertificateGatewaysForIngress(ingress, gateways)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resolved, err := c.getGatewaysByName(gateways, ingress.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.recordUnknownGateways(ingress, gateways, resolved, ingress.Namespace)
+
+	// Take ownership of an existing VirtualService if requested
+	var adopt *istionetworkingv1beta1.VirtualService
+	if vs == nil {
+		adopt, err = c.findVirtualServiceToAdopt(ingress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existing := vs
+	if adopt != nil {
+		existing = adopt
+	}
+
+	nvs, err := c.generateVirtualService(ingress, existing, gateways)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.dryRun {
+		return c.dryRunVirtualService(ingress, existing, nvs, adopt != nil || vs == nil || virtualServiceChanged(c.identity.managedBy, vs, nvs))
+	}
+
+	if adopt != nil {
+		_, confirmed := getAdoptVirtualServiceName(c.identity, ingress)
+		vs, err = c.adoptVirtualService(ingress, adopt, nvs, confirmed)
+		if err != nil || vs == nil {
+			return nil, err
+		}
+		virtualServiceOperationsTotal.WithLabelValues("update").Inc()
+	} else if vs == nil {
+		// If we don't have virtual service, then let's make one.
+		// Generated names cannot be applied, so these are created and their fields handed to the apply manager.
+		if nvs.Name == "" {
+			vs, err = c.istioNetworking.VirtualServices(ingress.Namespace).Create(ctx, nvs, metav1.CreateOptions{FieldManager: c.identity.managedBy})
+			if err == nil {
+				vs, err = c.istioNetworking.upgradeVirtualServiceManagedFields(ctx, vs)
+			}
+		} else {
+			vs, err = c.istioNetworking.applyVirtualService(ctx, nvs, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		virtualServiceOperationsTotal.WithLabelValues("create").Inc()
+		c.recorder.Eventf(ingress, corev1.EventTypeNormal, ReasonVirtualServiceCreated, "Created virtualservice %q", vs.Name)
+	} else if hasLegacyManagedFields(c.identity.managedBy, vs.ManagedFields) || virtualServiceChanged(c.identity.managedBy, vs, nvs) {
+		klog.InfoS("updating virtual service", c.logValues(ingress.Namespace, ingress.Name)...)
+
+		// The fields created or updated before the VirtualServices were applied are handed to the apply manager first
+		if hasLegacyManagedFields(c.identity.managedBy, vs.ManagedFields) {
+			if _, err = c.istioNetworking.upgradeVirtualServiceManagedFields(ctx, vs); err != nil {
+				return nil, err
+			}
+		}
+
+		vs, err = c.istioNetworking.applyVirtualService(ctx, nvs, false)
+		if err != nil {
+			return nil, err
+		}
+		virtualServiceOperationsTotal.WithLabelValues("update").Inc()
+		c.recorder.Eventf(ingress, corev1.EventTypeNormal, ReasonVirtualServiceUpdated, "Updated virtualservice %q", vs.Name)
+	}
+
+	c.reconcileLogs.setVirtualService(fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name), vs.Name)
+
+	if c.deterministicNames {
+		err = c.removeDuplicateVirtualServices(ingress, vs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vs, nil
+}
+
+// virtualServiceChanged determines if applying the generated VirtualService under the field manager changes the existing one.
+// Labels and annotations of other field managers are preserved by the server,
+// while those previously applied by the controller and no longer generated are removed.
+func virtualServiceChanged(manager string, vs, nvs *istionetworkingv1beta1.VirtualService) bool {
+	return !mapContains(vs.ObjectMeta.Labels, nvs.ObjectMeta.Labels) || !mapContains(vs.ObjectMeta.Annotations, nvs.ObjectMeta.Annotations) ||
+		appliedKeysRemoved(manager, vs.ManagedFields, "labels", nvs.ObjectMeta.Labels) || appliedKeysRemoved(manager, vs.ManagedFields, "annotations", nvs.ObjectMeta.Annotations) ||
+		virtualServiceSpecChanged(manager, vs, nvs)
+}
+
+// virtualServiceSpecChanged determines if applying the generated spec changes the existing one.
+// Only the fields generated by the controller are compared, as the fields of other field managers,
+// such as the tcp routes of an adopted VirtualService, are preserved by the server.
+func virtualServiceSpecChanged(manager string, vs, nvs *istionetworkingv1beta1.VirtualService) bool {
+	if !stringArrayEquals(vs.Spec.Hosts, nvs.Spec.Hosts) || !stringArrayEquals(vs.Spec.Gateways, nvs.Spec.Gateways) {
+		return true
+	}
+
+	if (len(vs.Spec.Http) > 0 || len(nvs.Spec.Http) > 0) && !reflect.DeepEqual(vs.Spec.Http, nvs.Spec.Http) {
+		return true
+	}
+
+	// Without a generated exportTo, the exportTo is only removed if it was applied by the controller
+	if len(nvs.Spec.ExportTo) == 0 {
+		return len(vs.Spec.ExportTo) > 0 && isAppliedSpecField(manager, vs.ManagedFields, "exportTo")
+	}
+
+	return !stringArrayEquals(vs.Spec.ExportTo, nvs.Spec.ExportTo)
+}
+
+// shouldHandleIngress determines if the Ingress is handled by the controller,
+// through the ingress class annotation, the IngressClass or the ignore annotation.
+// The decision is logged when reconciling, unlike the debug endpoints and admission reviews.
+func (c *Controller) shouldHandleIngress(ingress *networkingv1.Ingress) (bool, error) {
+	handle, reason, err := c.getIngressHandling(ingress)
+	if err != nil {
+		klog.ErrorS(err, "error determining if the ingress is handled", c.logValues(ingress.Namespace, ingress.Name)...)
+		return false, err
+	}
+
+	if val, ok := ingress.Annotations[IngressClassAnnotation]; ok && c.ingressClass != "" && val == c.ingressClass {
+		klog.InfoS("deprecated ingress class annotation set and takes precedence over ingressClassName", c.logValues(ingress.Namespace, ingress.Name, "annotation", IngressClassAnnotation, "ingressClass", c.ingressClass)...)
+	}
+	klog.V(4).InfoS("determined ingress handling", c.logValues(ingress.Namespace, ingress.Name, "handle", handle, "reason", reason)...)
+
+	return handle, nil
+}
+
+// getIngressHandling returns whether the Ingress is handled by the controller and why, without logging.
+func (c *Controller) getIngressHandling(ingress *networkingv1.Ingress) (bool, string, error) {
+	if !c.namespaceMatches(ingress.Namespace) {
+		return false, "namespace does not match the namespace selector", nil
+	}
 
 	// Check for conditions which cause us to handle the Ingress
 	handle := false
+	reason := "no matching ingress class annotation or IngressClass"
 	// Determines if the IngressClassAnnotation is set - to preserve backwards compatibility.
 	hasIngressClassAnnotation := false
 	var ingressClassAnnotationValue string
 
 	// If the IngressClassAnnotation is set, handle. This takes precedence over the IngressClass.
 	if ingressClassAnnotationValue, hasIngressClassAnnotation = ingress.Annotations[IngressClassAnnotation]; hasIngressClassAnnotation && c.ingressClass != "" && ingressClassAnnotationValue == c.ingressClass {
-		klog.Infof("deprecated annotation \"%s=%s\" set and takes precedence over ingressClassName for Ingress: \"%s/%s\"", IngressClassAnnotation, c.ingressClass, ingress.Namespace, ingress.Name)
 		handle = true
+		reason = fmt.Sprintf("annotation %s=%s", IngressClassAnnotation, c.ingressClass)
 	}
 
 	// Ensure that if it has an IngressClassAnnotation, it doesn't handle via the
@@ -68,87 +273,99 @@ func (c *Controller) handleVirtualServiceForIngress(ingress *networkingv1.Ingres
 	if !hasIngressClassAnnotation && ingress.Spec.IngressClassName != nil {
 		ingressClass, err := c.ingressClassesLister.Get(*ingress.Spec.IngressClassName)
 		if err != nil {
-			klog.Error("error getting IngressClass %q", *ingress.Spec.IngressClassName)
-			return nil, err
+			return false, "", err
 		}
 
-		if ingressClass.Spec.Controller == IngressIstioController {
-			klog.Infof("IngressClass set to \"%s\" - handling Ingress", IngressIstioController)
+		if ingressClass.Spec.Controller == c.identity.controllerName {
 			handle = true
+			reason = fmt.Sprintf("IngressClass %q", ingressClass.Name)
 		}
 	}
 
 	// Explicit ignore annotation
-	if val, ok := ingress.Annotations[IgnoreAnnotation]; ok {
+	if val, ok := ingress.Annotations[c.identity.key(IgnoreAnnotation)]; ok {
 		bval, err := strconv.ParseBool(val)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing %s (%t): %v", IgnoreAnnotation, bval, err)
+			return false, "", newReconcileError(ReasonInvalidAnnotation, "error parsing %s (%q): %v", c.identity.key(IgnoreAnnotation), val, err)
+		}
+		if handle && bval {
+			reason = fmt.Sprintf("annotation %s=%s", c.identity.key(IgnoreAnnotation), val)
 		}
 		handle = handle && !bval
 	}
 
-	if !handle {
-		// A VirtualService already exists, so let's delete it
-		if vs != nil {
-			klog.Infof("removing owned virtualservice: \"%s/%s\"", vs.Namespace, vs.Name)
-			err := c.istioclientset.NetworkingV1beta1().VirtualServices(vs.Namespace).Delete(ctx, vs.Name, metav1.DeleteOptions{})
-			return nil, err
-		}
-
-		klog.Infof("skipping ingress: \"%s/%s\"", ingress.Namespace, ingress.Name)
-		return nil, nil
-	}
+	return handle, reason, nil
+}
 
-	// Identify the gateway to attach the ingress to
+// getGatewayNamesForIngress returns the names of the gateways to which the Ingress is attached.
+func (c *Controller) getGatewayNamesForIngress(ingress *networkingv1.Ingress) []string {
 	gateways := []string{c.defaultGateway}
 
-	if val, ok := ingress.Annotations[GatewaysAnnotation]; ok {
+	if val, ok := ingress.Annotations[c.identity.key(GatewaysAnnotation)]; ok {
 		gateways = strings.Split(val, ",")
-		klog.Infof("using override gateways for \"%s/%s\": %s", ingress.Namespace, ingress.Name, gateways)
+		klog.V(4).InfoS("using override gateways", c.logValues(ingress.Namespace, ingress.Name, "gateways", gateways)...)
 	}
 
-	nvs, err := c.generateVirtualService(ingress, vs, gateways)
-	if err != nil {
-		return nil, err
+	return gateways
+}
+
+// getExportToForIngress returns the namespaces to which the VirtualService of the Ingress is exported.
+func (c *Controller) getExportToForIngress(ingress *networkingv1.Ingress) []string {
+	if val, ok := ingress.Annotations[c.identity.key(ExportToAnnotation)]; ok {
+		return splitList(val)
 	}
 
-	// If we don't have virtual service, then let's make one
-	if vs == nil {
-		vs, err = c.istioclientset.NetworkingV1beta1().VirtualServices(ingress.Namespace).Create(ctx, nvs, metav1.CreateOptions{})
-		if err != nil {
-			return nil, err
+	return c.defaultExportTo
+}
+
+// isExportedToNamespace determines if a resource of the namespace exported to the
+// given namespaces is visible in another namespace. No namespaces exports to all namespaces.
+func isExportedToNamespace(exportTo []string, resourceNamespace, namespace string) bool {
+	if len(exportTo) == 0 {
+		return true
+	}
+
+	for _, e := range exportTo {
+		if e == "*" || e == namespace || (e == "." && namespace == resourceNamespace) {
+			return true
 		}
-	} else if !reflect.DeepEqual(vs.ObjectMeta.Labels, nvs.ObjectMeta.Labels) || !reflect.DeepEqual(vs.ObjectMeta.Annotations, nvs.ObjectMeta.Annotations) || !reflect.DeepEqual(vs.Spec, nvs.Spec) {
-		klog.Infof("updating virtual service \"%s/%s\"", vs.Namespace, vs.Name)
+	}
 
-		uvs := vs.DeepCopy()
+	return false
+}
 
-		// Copy the new spec
-		uvs.ObjectMeta.Labels = nvs.ObjectMeta.Labels
-		uvs.ObjectMeta.Annotations = nvs.ObjectMeta.Annotations
-		uvs.Spec = nvs.Spec
+// getWorkloadNamespacesForGateways returns the namespaces in which the workloads of the gateways run.
+func (c *Controller) getWorkloadNamespacesForGateways(gateways []*istionetworkingv1beta1.Gateway) ([]string, error) {
+	namespaces := []string{}
 
-		vs, err = c.istioclientset.NetworkingV1beta1().VirtualServices(ingress.Namespace).Update(ctx, uvs, metav1.UpdateOptions{})
+	for _, gateway := range gateways {
+		workloadNamespaces, err := c.getWorkloadNamespacesForGateway(gateway)
 		if err != nil {
 			return nil, err
 		}
+
+		for _, namespace := range workloadNamespaces {
+			if !stringInArray(namespace, namespaces) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
 	}
 
-	return vs, nil
+	return namespaces, nil
 }
 
-func generateObjectMetadata(ingress *networkingv1.Ingress, existingVirtualService *istionetworkingv1beta1.VirtualService) (labels map[string]string, annotations map[string]string) {
-	if existingVirtualService != nil {
-		labels = existingVirtualService.DeepCopy().Labels
-		annotations = existingVirtualService.DeepCopy().Annotations
-	}
+// generateObjectMetadata generates the metadata of a routing resource for the Ingress,
+// preserving the labels and annotations of the existing resource.
+func generateObjectMetadata(id *Identity, ingress *networkingv1.Ingress, existingLabels, existingAnnotations map[string]string) (labels map[string]string, annotations map[string]string) {
+	labels = make(map[string]string)
+	annotations = make(map[string]string)
 
-	if labels == nil {
-		labels = make(map[string]string)
+	for k, v := range existingLabels {
+		labels[k] = v
 	}
 
-	if annotations == nil {
-		annotations = make(map[string]string)
+	for k, v := range existingAnnotations {
+		annotations[k] = v
 	}
 
 	// Overwrite with metadata from ingress
@@ -161,22 +378,23 @@ func generateObjectMetadata(ingress *networkingv1.Ingress, existingVirtualServic
 	}
 
 	// Overwrite metadata with controller information
-	labels["app.kubernetes.io/managed-by"] = controllerAgentName
-	labels["app.kubernetes.io/created-by"] = controllerAgentName
+	labels[managedByLabel] = id.managedBy
+	labels["app.kubernetes.io/created-by"] = id.managedBy
 	annotations["meta.statcan.gc.ca/version"] = controllerAgentVersion
 
 	return
 }
 
 func (c *Controller) generateVirtualService(ingress *networkingv1.Ingress, existingVirtualService *istionetworkingv1beta1.VirtualService, gatewayNames []string) (*istionetworkingv1beta1.VirtualService, error) {
-	labels, annotations := generateObjectMetadata(ingress, existingVirtualService)
+	// The metadata of the existing VirtualService is not merged, as the VirtualService is applied
+	// and the labels and annotations of other field managers are preserved by the server.
+	labels, annotations := generateObjectMetadata(c.identity, ingress, nil, nil)
 
 	vs := &istionetworkingv1beta1.VirtualService{
 		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: fmt.Sprintf("%s-", ingress.Name),
-			Namespace:    ingress.Namespace,
+			Namespace: ingress.Namespace,
 			OwnerReferences: []metav1.OwnerReference{
-				*metav1.NewControllerRef(ingress, networkingv1.SchemeGroupVersion.WithKind("Ingress")),
+				ingressOwnerReference(ingress),
 			},
 			Labels:      labels,
 			Annotations: annotations,
@@ -188,16 +406,45 @@ func (c *Controller) generateVirtualService(ingress *networkingv1.Ingress, exist
 		},
 	}
 
+	if existingVirtualService != nil {
+		vs.Name = existingVirtualService.Name
+	} else if c.deterministicNames {
+		vs.Name = virtualServiceName(ingress.Name)
+	} else {
+		vs.GenerateName = fmt.Sprintf("%s-", ingress.Name)
+	}
+
+	exportTo := c.getExportToForIngress(ingress)
+	if len(exportTo) > 0 {
+		vs.Spec.ExportTo = exportTo
+	}
+
 	gateways, err := c.getGatewaysByName(gatewayNames, vs.Namespace)
 	if err != nil {
 		return nil, err
 	}
 
+	// The VirtualService must remain visible to the workloads of its gateways
+	// or, for delegates, to the root VirtualService.
+	visibleNamespaces, err := c.getWorkloadNamespacesForGateways(gateways)
+	if err != nil {
+		return nil, err
+	}
+	if c.rootVirtualService != "" {
+		rootNamespace, _ := c.splitRootVirtualService()
+		visibleNamespaces = append(visibleNamespaces, rootNamespace)
+	}
+	for _, namespace := range visibleNamespaces {
+		if !isExportedToNamespace(exportTo, vs.Namespace, namespace) {
+			return nil, newTerminalReconcileError(ReasonInvalidAnnotation, "invalid export to for \"%s/%s\": the virtual service is not exported to the namespace %q", ingress.Namespace, ingress.Name, namespace)
+		}
+	}
+
 	portsOnGateways := c.getNonHTTPPRedirectPortsOnGateways(gateways)
 
 	for _, rule := range ingress.Spec.Rules {
 		if rule.HTTP == nil {
-			return nil, fmt.Errorf("invalid ingress rule: \"%s/%s\" - no http definition", ingress.Namespace, ingress.Name)
+			return nil, newReconcileError(ReasonMissingHTTPRules, "invalid ingress rule: \"%s/%s\" - no http definition", ingress.Namespace, ingress.Name)
 		}
 
 		// Add the host
@@ -220,6 +467,13 @@ func (c *Controller) generateVirtualService(ingress *networkingv1.Ingress, exist
 		}
 	}
 
+	// Delegates are attached to the gateways through the root VirtualService,
+	// and therefore must not specify hosts or gateways.
+	if c.rootVirtualService != "" {
+		vs.Spec.Gateways = nil
+		vs.Spec.Hosts = nil
+	}
+
 	return vs, nil
 }
 
@@ -229,8 +483,8 @@ func (c *Controller) getNonHTTPPRedirectPortsOnGateways(gateways []*istionetwork
 
 	for _, gateway := range gateways {
 		for _, server := range gateway.Spec.Servers {
-			if !server.Tls.HttpsRedirect {
-				ports = append(ports, server.Port.Number)
+			if !server.GetTls().GetHttpsRedirect() {
+				ports = append(ports, server.GetPort().GetNumber())
 			}
 		}
 	}
@@ -244,6 +498,11 @@ func (c *Controller) createHTTPRoutesForPath(ingress *networkingv1.Ingress, host
 		return nil, err
 	}
 
+	destinationHost, err := c.getDestinationHost(ingress.Namespace, path.Backend)
+	if err != nil {
+		return nil, err
+	}
+
 	var authorityMatches []*v1beta1.StringMatch
 
 	if strings.Contains(host, "*") {
@@ -270,7 +529,7 @@ func (c *Controller) createHTTPRoutesForPath(ingress *networkingv1.Ingress, host
 			Route: []*v1beta1.HTTPRouteDestination{
 				{
 					Destination: &v1beta1.Destination{
-						Host: fmt.Sprintf("%s.%s.svc.%s", path.Backend.Service.Name, ingress.Namespace, c.clusterDomain),
+						Host: destinationHost,
 						Port: &v1beta1.PortSelector{
 							Number: servicePort,
 						},
@@ -312,7 +571,9 @@ func (c *Controller) getServicePort(namespace string, backend networkingv1.Ingre
 	} else if backend.Service.Port.Name != "" {
 		// Find the service and conver the service name to a port
 		service, err := c.servicesLister.Services(namespace).Get(backend.Service.Name)
-		if err != nil {
+		if errors.IsNotFound(err) {
+			return 0, newReconcileError(ReasonMissingBackendService, "backend service \"%s/%s\" does not exist", namespace, backend.Service.Name)
+		} else if err != nil {
 			return 0, err
 		}
 
@@ -321,6 +582,8 @@ func (c *Controller) getServicePort(namespace string, backend networkingv1.Ingre
 				return uint32(port.Port), nil
 			}
 		}
+
+		return 0, newReconcileError(ReasonUnresolvablePort, "port %q of backend service \"%s/%s\" does not exist", backend.Service.Port.Name, namespace, backend.Service.Name)
 	}
 
 	return 0, fmt.Errorf("unknown backend service port type")
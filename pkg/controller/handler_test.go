@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGenerateVirtualServiceExportTo(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Number: 80}
+	backend := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+	}
+	gatewayService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-ingressgateway", Namespace: "ingress", Labels: map[string]string{"istio": "ingressgateway"}},
+	}
+
+	tests := []struct {
+		name     string
+		gateways string
+		exportTo string
+		terminal bool
+	}{
+		{
+			name:     "exported to the workloads of the gateway",
+			gateways: "istio-system/ingressgateway",
+			exportTo: ".,ingress",
+		},
+		{
+			name:     "exported to all namespaces",
+			gateways: "istio-system/ingressgateway",
+			exportTo: "*",
+		},
+		{
+			name:     "exported to the namespace of the gateway only",
+			gateways: "istio-system/ingressgateway",
+			exportTo: "istio-system",
+			terminal: true,
+		},
+		{
+			name:     "not exported to the workloads of the gateway",
+			gateways: "istio-system/ingressgateway",
+			exportTo: ".",
+			terminal: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestController(t, Config{}, backend, gatewayService, testGateway("istio-system", "ingressgateway"))
+			ingress := testIngress("web", "a.example.com", "/", "web", http80, map[string]string{
+				GatewaysAnnotation: test.gateways,
+				ExportToAnnotation: test.exportTo,
+			})
+
+			_, err := c.generateVirtualService(ingress, nil, c.getGatewayNamesForIngress(ingress))
+			if test.terminal {
+				if !isTerminalReconcileError(err) {
+					t.Errorf("expected a terminal error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetNonHTTPPRedirectPortsOnGateways(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []*v1beta1.Server
+		ports   []uint32
+	}{
+		{
+			name: "server without tls settings",
+			servers: []*v1beta1.Server{
+				{Port: &v1beta1.Port{Number: 80, Protocol: "HTTP", Name: "http"}, Hosts: []string{"*"}},
+			},
+			ports: []uint32{80},
+		},
+		{
+			name: "https redirect",
+			servers: []*v1beta1.Server{
+				{Port: &v1beta1.Port{Number: 80, Protocol: "HTTP", Name: "http"}, Hosts: []string{"*"}, Tls: &v1beta1.ServerTLSSettings{HttpsRedirect: true}},
+				{Port: &v1beta1.Port{Number: 443, Protocol: "HTTPS", Name: "https"}, Hosts: []string{"*"}, Tls: &v1beta1.ServerTLSSettings{Mode: v1beta1.ServerTLSSettings_SIMPLE}},
+			},
+			ports: []uint32{443},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gateway := &istionetworkingv1beta1.Gateway{Spec: v1beta1.Gateway{Servers: test.servers}}
+
+			ports := (&Controller{}).getNonHTTPPRedirectPortsOnGateways([]*istionetworkingv1beta1.Gateway{gateway})
+			if diff := cmp.Diff(test.ports, ports); diff != "" {
+				t.Errorf("unexpected ports (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// livenessTimeout is how long a worker may take to complete an item, or the workers may go
+// without picking up pending work, before the controller is reported as not alive.
+const livenessTimeout = 5 * time.Minute
+
+// workerProgress tracks whether the workers of the controller are making progress.
+// The items being processed are tracked individually, so that a stuck worker is detected
+// while the other workers keep completing items.
+type workerProgress struct {
+	lock         sync.Mutex
+	running      bool
+	nextID       uint64
+	inFlight     map[uint64]time.Time
+	lastProgress time.Time
+}
+
+// start records that the workers were started.
+func (p *workerProgress) start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.running = true
+	p.lastProgress = time.Now()
+}
+
+// stop records that the workers were stopped.
+func (p *workerProgress) stop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.running = false
+}
+
+// begin records that a worker picked up an item and returns its ID, which is passed to done.
+func (p *workerProgress) begin() uint64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.inFlight == nil {
+		p.inFlight = map[uint64]time.Time{}
+	}
+
+	p.nextID++
+	p.inFlight[p.nextID] = time.Now()
+	p.lastProgress = time.Now()
+
+	return p.nextID
+}
+
+// done records that a worker completed the item.
+func (p *workerProgress) done(id uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.inFlight, id)
+	p.lastProgress = time.Now()
+}
+
+// stalled returns for how long the oldest item has been processed or, when no item is,
+// for how long pending work has not been picked up. It is zero when the workers are idle.
+func (p *workerProgress) stalled(pending int) time.Duration {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.running {
+		return 0
+	}
+
+	if len(p.inFlight) > 0 {
+		var oldest time.Time
+		for _, started := range p.inFlight {
+			if oldest.IsZero() || started.Before(oldest) {
+				oldest = started
+			}
+		}
+		return time.Since(oldest)
+	}
+
+	if pending == 0 {
+		return 0
+	}
+
+	return time.Since(p.lastProgress)
+}
+
+var leader struct {
+	lock     sync.Mutex
+	isLeader bool
+}
+
+// SetLeader records whether this replica holds the leader lease.
+func SetLeader(isLeader bool) {
+	leader.lock.Lock()
+	defer leader.lock.Unlock()
+
+	leader.isLeader = isLeader
+	if isLeader {
+		leaderGauge.Set(1)
+	} else {
+		leaderGauge.Set(0)
+	}
+}
+
+// IsLeader returns whether this replica holds the leader lease.
+func IsLeader() bool {
+	leader.lock.Lock()
+	defer leader.lock.Unlock()
+
+	return leader.isLeader
+}
+
+// informersSynched returns the functions reporting whether the informer caches of the controller have synced, by informer.
+func (c *Controller) informersSynched() map[string]cache.InformerSynced {
+	synched := map[string]cache.InformerSynced{
+		"ingresses":             c.ingressesSynched,
+		"ingressclasses":        c.ingressClassesSynched,
+		"services":              c.servicesSynched,
+		"gatewayservices":       c.gatewayServicesSynched,
+		"nodes":                 c.nodesSynched,
+		"virtualservices":       c.virtualServicesSynched,
+		"gateways":              c.gatewaysSynched,
+		"authorizationpolicies": c.authorizationPoliciesSynched,
+		"envoyfilters":          c.envoyFiltersSynched,
+		"serviceentries":        c.serviceEntriesSynched,
+		"destinationrules":      c.destinationRulesSynched,
+	}
+	if c.httpRoutesSynched != nil {
+		synched["httproutes"] = c.httpRoutesSynched
+	}
+	if c.gatewayAPIGatewaysSynched != nil {
+		synched["gatewayapigateways"] = c.gatewayAPIGatewaysSynched
+	}
+	if c.namespacesSynched != nil {
+		synched["namespaces"] = c.namespacesSynched
+	}
+
+	return synched
+}
+
+// HealthzHandler returns the handler reporting whether the workers of the controller are making progress.
+func (c *Controller) HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if stalled := c.progress.stalled(c.workqueue.Len()); stalled > livenessTimeout {
+			http.Error(w, fmt.Sprintf("workers have not progressed in %s", stalled.Truncate(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// ReadyzHandler returns the handler reporting whether the informer caches of the controller have synced.
+func (c *Controller) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pending := []string{}
+		for name, synched := range c.informersSynched() {
+			if !synched() {
+				pending = append(pending, name)
+			}
+		}
+
+		if len(pending) > 0 {
+			sort.Strings(pending)
+			http.Error(w, fmt.Sprintf("informer caches not synced: %v", pending), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// LeaderHandler returns the handler reporting whether this replica holds the leader lease.
+// Replicas which are not the leader respond with a 503 status.
+func LeaderHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsLeader() {
+			http.Error(w, "false", http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "true")
+	})
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorkerProgressStalled(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		running      bool
+		inFlight     []time.Time
+		lastProgress time.Time
+		pending      int
+		want         time.Duration
+	}{
+		{
+			name:         "not running",
+			inFlight:     []time.Time{now.Add(-time.Hour)},
+			lastProgress: now.Add(-time.Hour),
+			pending:      1,
+		},
+		{
+			name:         "idle",
+			running:      true,
+			lastProgress: now.Add(-time.Hour),
+		},
+		{
+			name:         "pending work not picked up",
+			running:      true,
+			lastProgress: now.Add(-time.Hour),
+			pending:      1,
+			want:         time.Hour,
+		},
+		{
+			name:         "stuck worker while the others progress",
+			running:      true,
+			inFlight:     []time.Time{now.Add(-time.Second), now.Add(-time.Hour), now},
+			lastProgress: now,
+			pending:      1,
+			want:         time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &workerProgress{running: test.running, inFlight: map[uint64]time.Time{}, lastProgress: test.lastProgress}
+			for i, started := range test.inFlight {
+				p.inFlight[uint64(i)] = started
+			}
+
+			// The time elapsed while testing is not counted
+			if got := p.stalled(test.pending); got < test.want || got > test.want+time.Minute {
+				t.Errorf("expected stalled for %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestWorkerProgressDone(t *testing.T) {
+	p := &workerProgress{}
+	p.start()
+
+	stuck := p.begin()
+	p.inFlight[stuck] = time.Now().Add(-time.Hour)
+	p.done(p.begin())
+
+	if stalled := p.stalled(0); stalled < time.Hour {
+		t.Errorf("expected the stuck item to be reported, got %s", stalled)
+	}
+
+	p.done(stuck)
+	if stalled := p.stalled(0); stalled != 0 {
+		t.Errorf("expected no stall once the items are done, got %s", stalled)
+	}
+}
@@ -0,0 +1,452 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// The Gateway API HTTPRoutes generated in the httproute output mode
+	HTTPRouteResource = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+	// The Gateway API Gateways to which the HTTPRoutes are attached
+	GatewayAPIGatewayResource = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+)
+
+// The maximum number of rules of an HTTPRoute, beyond which the rules of a host are split across several HTTPRoutes.
+const maxHTTPRouteRules = 16
+
+// httpRouteOutput translates Ingresses into Gateway API HTTPRoutes.
+// One HTTPRoute is generated for each host of the Ingress, as the hostnames
+// of an HTTPRoute apply to all of its rules.
+type httpRouteOutput struct {
+	controller *Controller
+}
+
+func (o *httpRouteOutput) sync(ingress *networkingv1.Ingress) (*corev1.LoadBalancerStatus, error) {
+	c := o.controller
+	ctx := context.Background()
+
+	// Remove the resources of the virtualservice output,
+	// in case the output mode of the controller was changed.
+	if err := o.removeVirtualServiceOutput(ingress); err != nil {
+		return nil, err
+	}
+
+	handle, err := c.shouldHandleIngress(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := []*unstructured.Unstructured{}
+	parentRefs := []interface{}{}
+
+	if handle {
+		parentRefs = c.generateParentRefs(ingress, c.getGatewayNamesForIngress(ingress))
+
+		desired, err = c.generateHTTPRoutes(ingress, parentRefs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		klog.InfoS("skipping ingress", c.logValues(ingress.Namespace, ingress.Name)...)
+	}
+
+	// The resources of these annotations were removed with the virtualservice output
+	unsupported := []string{}
+	if handle {
+		for _, annotation := range []string{c.identity.key(AuthProviderAnnotation), c.identity.key(ClientCASecretAnnotation), c.identity.key(RateLimitRequestsAnnotation)} {
+			if _, ok := ingress.Annotations[annotation]; ok {
+				unsupported = append(unsupported, fmt.Sprintf("Annotation %s is not supported in the httproute output mode, its resources were removed", annotation))
+			}
+		}
+	}
+	c.recordWarnings(ingress, ReasonUnsupportedAnnotation, unsupported)
+
+	// The ExternalName Services are routed through the ServiceEntries of their external names
+	if err := c.handleExternalServicesForIngress(ingress, handle); err != nil {
+		return nil, err
+	}
+
+	existing, err := c.findExistingHTTPRoutesForIngress(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.dynamicclientset.Resource(HTTPRouteResource).Namespace(ingress.Namespace)
+
+	for _, route := range desired {
+		var current *unstructured.Unstructured
+		for _, er := range existing {
+			if er.GetName() == route.GetName() {
+				current = er
+				break
+			}
+		}
+
+		if current == nil {
+			klog.InfoS("creating http route", c.logValues(ingress.Namespace, ingress.Name, "httpRoute", klog.KObj(route))...)
+			_, err = client.Create(ctx, route, metav1.CreateOptions{})
+			if err != nil {
+				return nil, err
+			}
+		} else if !reflect.DeepEqual(current.GetLabels(), route.GetLabels()) || !reflect.DeepEqual(current.GetAnnotations(), route.GetAnnotations()) || !reflect.DeepEqual(current.Object["spec"], route.Object["spec"]) {
+			klog.InfoS("updating http route", c.logValues(ingress.Namespace, ingress.Name, "httpRoute", klog.KObj(route))...)
+
+			updated := current.DeepCopy()
+			updated.SetLabels(route.GetLabels())
+			updated.SetAnnotations(route.GetAnnotations())
+			updated.Object["spec"] = route.Object["spec"]
+
+			_, err = client.Update(ctx, updated, metav1.UpdateOptions{})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, er := range existing {
+		found := false
+		for _, route := range desired {
+			if er.GetName() == route.GetName() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			klog.InfoS("removing owned http route", c.logValues(ingress.Namespace, ingress.Name, "httpRoute", klog.KObj(er))...)
+			err = client.Delete(ctx, er.GetName(), metav1.DeleteOptions{})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !handle {
+		return nil, nil
+	}
+
+	loadBalancerStatus, err := c.getLoadBalancerStatusForParentRefs(ingress, parentRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loadBalancerStatus, nil
+}
+
+func (o *httpRouteOutput) status(ingress *networkingv1.Ingress) (*corev1.LoadBalancerStatus, error) {
+	c := o.controller
+
+	existing, err := c.findExistingHTTPRoutesForIngress(ingress)
+	if err != nil || len(existing) == 0 {
+		return nil, err
+	}
+
+	loadBalancerStatus, err := c.getLoadBalancerStatusForParentRefs(ingress, c.generateParentRefs(ingress, c.getGatewayNamesForIngress(ingress)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &loadBalancerStatus, nil
+}
+
+// removeVirtualServiceOutput removes the VirtualService owned by the Ingress
+// and the resources generated alongside it, except for the resources of the ExternalName Services.
+func (o *httpRouteOutput) removeVirtualServiceOutput(ingress *networkingv1.Ingress) error {
+	c := o.controller
+
+	vs, err := c.findExistingVirtualServiceForIngress(ingress)
+	if err != nil {
+		return err
+	}
+
+	if vs != nil {
+		klog.InfoS("removing owned virtualservice", c.logValues(ingress.Namespace, ingress.Name, "virtualService", vs.Name)...)
+		err = c.istioNetworking.VirtualServices(vs.Namespace).Delete(context.Background(), vs.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		virtualServiceOperationsTotal.WithLabelValues("delete").Inc()
+		c.recorder.Eventf(ingress, corev1.EventTypeNormal, ReasonVirtualServiceDeleted, "Deleted virtualservice %q", vs.Name)
+	}
+
+	return c.removeResourcesForIngress(ingress.Namespace, ingress.Name)
+}
+
+// findExistingHTTPRoutesForIngress returns the HTTPRoutes owned by the Ingress.
+func (c *Controller) findExistingHTTPRoutesForIngress(ingress *networkingv1.Ingress) ([]*unstructured.Unstructured, error) {
+	objs, err := c.httpRoutesLister.ByNamespace(ingress.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []*unstructured.Unstructured{}
+	for _, obj := range objs {
+		route, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		if metav1.IsControlledBy(route, ingress) && c.identity.isManagedByController(route.GetLabels()) {
+			routes = append(routes, route)
+		}
+	}
+
+	return routes, nil
+}
+
+// generateParentRefs converts the gateway names of the Ingress into HTTPRoute parentRefs.
+// The names are in the form of "namespace/name", defaulting to the namespace of the Ingress.
+func (c *Controller) generateParentRefs(ingress *networkingv1.Ingress, gatewayNames []string) []interface{} {
+	parentRefs := []interface{}{}
+
+	for _, gatewayName := range gatewayNames {
+		gatewayName = strings.TrimSpace(gatewayName)
+		if gatewayName == "mesh" {
+			klog.InfoS("the mesh gateway is not supported by the httproute output mode, ignoring", c.logValues(ingress.Namespace, ingress.Name)...)
+			continue
+		}
+
+		namespace := ingress.Namespace
+		name := gatewayName
+		if idParts := strings.SplitN(gatewayName, "/", 2); len(idParts) == 2 {
+			namespace = idParts[0]
+			name = idParts[1]
+		}
+
+		parentRefs = append(parentRefs, map[string]interface{}{
+			"group":     GatewayAPIGatewayResource.Group,
+			"kind":      "Gateway",
+			"namespace": namespace,
+			"name":      name,
+		})
+	}
+
+	return parentRefs
+}
+
+// generateHTTPRoutes generates an HTTPRoute for each of the hosts of the Ingress,
+// or several when the host has more than maxHTTPRouteRules rules.
+func (c *Controller) generateHTTPRoutes(ingress *networkingv1.Ingress, parentRefs []interface{}) ([]*unstructured.Unstructured, error) {
+	hosts := []string{}
+	rulesByHost := map[string][]interface{}{}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			return nil, newReconcileError(ReasonMissingHTTPRules, "invalid ingress rule: \"%s/%s\" - no http definition", ingress.Namespace, ingress.Name)
+		}
+
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+		if !stringInArray(host, hosts) {
+			hosts = append(hosts, host)
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			routeRule, err := c.createHTTPRouteRuleForPath(ingress, path)
+			if err != nil {
+				return nil, err
+			}
+
+			rulesByHost[host] = append(rulesByHost[host], routeRule)
+		}
+	}
+
+	routes := []*unstructured.Unstructured{}
+
+	for _, host := range hosts {
+		for i := 0; i*maxHTTPRouteRules < len(rulesByHost[host]); i++ {
+			end := (i + 1) * maxHTTPRouteRules
+			if end > len(rulesByHost[host]) {
+				end = len(rulesByHost[host])
+			}
+
+			// The first HTTPRoute of a host keeps the name of the HTTPRoutes which were not split
+			name := generatedName(ingress.Name, hashHost(host))
+			if i > 0 {
+				name = generatedName(ingress.Name, hashHost(host), strconv.Itoa(i))
+			}
+
+			routes = append(routes, c.generateHTTPRoute(ingress, name, host, parentRefs, rulesByHost[host][i*maxHTTPRouteRules:end]))
+		}
+	}
+
+	return routes, nil
+}
+
+// generateHTTPRoute generates an HTTPRoute of the Ingress with the rules of the host.
+func (c *Controller) generateHTTPRoute(ingress *networkingv1.Ingress, name, host string, parentRefs []interface{}, rules []interface{}) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"parentRefs": parentRefs,
+		"rules":      rules,
+	}
+
+	// A catch-all host is represented by the absence of hostnames
+	if host != "*" {
+		spec["hostnames"] = []interface{}{host}
+	}
+
+	route := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": HTTPRouteResource.GroupVersion().String(),
+			"kind":       "HTTPRoute",
+			"spec":       spec,
+		},
+	}
+
+	var existingLabels, existingAnnotations map[string]string
+	if existing, err := c.httpRoutesLister.ByNamespace(ingress.Namespace).Get(name); err == nil {
+		if er, ok := existing.(*unstructured.Unstructured); ok {
+			existingLabels = er.GetLabels()
+			existingAnnotations = er.GetAnnotations()
+		}
+	}
+
+	labels, annotations := generateObjectMetadata(c.identity, ingress, existingLabels, existingAnnotations)
+
+	route.SetName(name)
+	route.SetNamespace(ingress.Namespace)
+	route.SetOwnerReferences([]metav1.OwnerReference{ingressOwnerReference(ingress)})
+	route.SetLabels(labels)
+	route.SetAnnotations(annotations)
+
+	return route
+}
+
+// createHTTPRouteRuleForPath converts an Ingress path into an HTTPRoute rule.
+func (c *Controller) createHTTPRouteRuleForPath(ingress *networkingv1.Ingress, path networkingv1.HTTPIngressPath) (map[string]interface{}, error) {
+	if path.Backend.Service == nil {
+		return nil, fmt.Errorf("invalid ingress path: \"%s/%s\" - only service backends are supported", ingress.Namespace, ingress.Name)
+	}
+
+	servicePort, err := c.getServicePort(ingress.Namespace, path.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	backendRef := map[string]interface{}{
+		"group":  "",
+		"kind":   "Service",
+		"name":   path.Backend.Service.Name,
+		"port":   int64(servicePort),
+		"weight": int64(c.defaultWeight),
+	}
+
+	// ExternalName Services are routed to the host of their ServiceEntry, as Istio does not resolve their cluster local name
+	service, err := c.servicesLister.Services(ingress.Namespace).Get(path.Backend.Service.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if service != nil && service.Spec.Type == corev1.ServiceTypeExternalName {
+		backendRef["group"] = istioNetworkingGroup
+		backendRef["kind"] = "Hostname"
+		backendRef["name"] = service.Spec.ExternalName
+	}
+
+	return map[string]interface{}{
+		"matches": []interface{}{
+			map[string]interface{}{
+				"path": createHTTPRoutePathMatch(path),
+			},
+		},
+		"backendRefs": []interface{}{backendRef},
+	}, nil
+}
+
+// Converts an Ingress path into an HTTPRoute path match.
+// The Prefix path type of an Ingress has the same element-wise semantics
+// as the PathPrefix type of an HTTPRoute.
+func createHTTPRoutePathMatch(path networkingv1.HTTPIngressPath) map[string]interface{} {
+	if path.PathType != nil {
+		switch *path.PathType {
+		case networkingv1.PathTypeExact:
+			return map[string]interface{}{"type": "Exact", "value": path.Path}
+		case networkingv1.PathTypePrefix:
+			return map[string]interface{}{"type": "PathPrefix", "value": path.Path}
+		}
+	}
+
+	// Fallback to the string matching of the virtualservice output
+	match := createFallbackStringMatch(path.Path)
+	if match == nil {
+		return map[string]interface{}{"type": "PathPrefix", "value": "/"}
+	}
+
+	if prefix := match.GetPrefix(); prefix != "" {
+		if prefix != "/" {
+			prefix = strings.TrimSuffix(prefix, "/")
+		}
+		return map[string]interface{}{"type": "PathPrefix", "value": prefix}
+	}
+
+	return map[string]interface{}{"type": "Exact", "value": match.GetExact()}
+}
+
+// getLoadBalancerStatusForParentRefs returns the status of the Load Balancer
+// from the addresses of the Gateway API Gateways referenced by the HTTPRoutes.
+func (c *Controller) getLoadBalancerStatusForParentRefs(ingress *networkingv1.Ingress, parentRefs []interface{}) (corev1.LoadBalancerStatus, error) {
+	loadBalancerStatus := corev1.LoadBalancerStatus{}
+
+	for _, ref := range parentRefs {
+		parentRef := ref.(map[string]interface{})
+		namespace := parentRef["namespace"].(string)
+		name := parentRef["name"].(string)
+
+		obj, err := c.gatewayAPIGatewaysLister.ByNamespace(namespace).Get(name)
+		if errors.IsNotFound(err) {
+			klog.ErrorS(err, "failed to load gateway", c.logValues(ingress.Namespace, ingress.Name, "gateway", klog.KRef(namespace, name))...)
+			continue
+		} else if err != nil {
+			return loadBalancerStatus, err
+		}
+
+		gateway, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		addresses, _, err := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+		if err != nil {
+			return loadBalancerStatus, err
+		}
+
+		for _, a := range addresses {
+			address, ok := a.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			value, _ := address["value"].(string)
+			if addressType, _ := address["type"].(string); addressType == "Hostname" {
+				loadBalancerStatus.Ingress = append(loadBalancerStatus.Ingress, corev1.LoadBalancerIngress{Hostname: value})
+			} else {
+				loadBalancerStatus.Ingress = append(loadBalancerStatus.Ingress, corev1.LoadBalancerIngress{IP: value})
+			}
+		}
+	}
+
+	return loadBalancerStatus, nil
+}
+
+// Returns a short stable hash of a host.
+func hashHost(host string) string {
+	h := fnv.New32a()
+	h.Write([]byte(host))
+
+	return fmt.Sprintf("%08x", h.Sum32())
+}
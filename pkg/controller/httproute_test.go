@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGenerateHTTPRoutes(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Number: 80}
+	external := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "app"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "api.example.org"},
+	}
+
+	// withPaths returns the Ingress routing the number of paths of its host to the service
+	withPaths := func(paths int, service string) *networkingv1.Ingress {
+		ingress := testIngress("web", "a.example.com", "/0", service, http80, nil)
+		for i := 1; i < paths; i++ {
+			path := *ingress.Spec.Rules[0].HTTP.Paths[0].DeepCopy()
+			path.Path = fmt.Sprintf("/%d", i)
+			ingress.Spec.Rules[0].HTTP.Paths = append(ingress.Spec.Rules[0].HTTP.Paths, path)
+		}
+		return ingress
+	}
+
+	tests := []struct {
+		name     string
+		ingress  *networkingv1.Ingress
+		rules    []int
+		backends []string
+	}{
+		{
+			name:     "single route",
+			ingress:  withPaths(maxHTTPRouteRules, "web"),
+			rules:    []int{maxHTTPRouteRules},
+			backends: []string{"/Service/web"},
+		},
+		{
+			name:     "rules split across routes",
+			ingress:  withPaths(maxHTTPRouteRules*2+1, "web"),
+			rules:    []int{maxHTTPRouteRules, maxHTTPRouteRules, 1},
+			backends: []string{"/Service/web"},
+		},
+		{
+			name:     "external name service",
+			ingress:  withPaths(1, "external"),
+			rules:    []int{1},
+			backends: []string{"networking.istio.io/Hostname/api.example.org"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestController(t, Config{}, external)
+			c.httpRoutesLister = cache.NewGenericLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}), HTTPRouteResource.GroupResource())
+
+			routes, err := c.generateHTTPRoutes(test.ingress, c.generateParentRefs(test.ingress, []string{"istio-system/gateway"}))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			rules := []int{}
+			names := map[string]bool{}
+			backends := []string{}
+			for _, route := range routes {
+				names[route.GetName()] = true
+
+				routeRules, _, err := unstructured.NestedSlice(route.Object, "spec", "rules")
+				if err != nil {
+					t.Fatal(err)
+				}
+				rules = append(rules, len(routeRules))
+
+				for _, rule := range routeRules {
+					refs, _, _ := unstructured.NestedSlice(rule.(map[string]interface{}), "backendRefs")
+					for _, ref := range refs {
+						r := ref.(map[string]interface{})
+						backend := fmt.Sprintf("%s/%s/%s", r["group"], r["kind"], r["name"])
+						if !stringInArray(backend, backends) {
+							backends = append(backends, backend)
+						}
+					}
+				}
+			}
+
+			if diff := cmp.Diff(test.rules, rules); diff != "" {
+				t.Errorf("unexpected rules per route (-want +got):\n%s", diff)
+			}
+			if len(names) != len(routes) {
+				t.Errorf("expected distinct names, got %v", names)
+			}
+			if !names[generatedName("web", hashHost("a.example.com"))] {
+				t.Errorf("expected the first route to keep the name of the host, got %v", names)
+			}
+			if diff := cmp.Diff(test.backends, backends); diff != "" {
+				t.Errorf("unexpected backends (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Defaults of the identity of the controller.
+const (
+	DefaultControllerName   = "ingress.statcan.gc.ca/ingress-istio-controller"
+	DefaultAnnotationPrefix = "ingress.statcan.gc.ca"
+	DefaultManagedBy        = "ingress-istio-controller"
+)
+
+// The label identifying the controller which manages a generated resource.
+const managedByLabel = "app.kubernetes.io/managed-by"
+
+// prefixedNames are the default keys of the annotations and labels whose prefix is the annotation prefix of the controller.
+var prefixedNames = []string{
+	IgnoreAnnotation,
+	GatewaysAnnotation,
+	ExportToAnnotation,
+	AdoptVirtualServiceAnnotation,
+	StatusSourceAnnotation,
+	StatusNodeSelectorAnnotation,
+	RateLimitRequestsAnnotation,
+	RateLimitUnitAnnotation,
+	RateLimitBurstAnnotation,
+	AuthProviderAnnotation,
+	AuthExcludedPathsAnnotation,
+	ClientCASecretAnnotation,
+	ClientVerificationAnnotation,
+	IngressNamespaceLabel,
+	IngressNameLabel,
+}
+
+// Identity is the identity of an instance of the controller, so that several instances can run in the same cluster:
+// the value of IngressClass.spec.controller it handles, the keys of the annotations it reads and of the labels it sets,
+// and the value of the managed-by label of the resources it generates, which are the only ones it changes.
+// The managed-by value is also the field manager and event source of the controller.
+type Identity struct {
+	controllerName string
+	managedBy      string
+	// Keys of the annotations and labels of the controller, by their default key
+	keys map[string]string
+}
+
+// NewIdentity validates and returns the identity of the controller.
+func NewIdentity(controllerName, annotationPrefix, managedBy string) (*Identity, error) {
+	if errs := validation.IsDomainPrefixedPath(nil, controllerName); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid controller name %q: %v", controllerName, errs.ToAggregate())
+	}
+
+	if errs := validation.IsDNS1123Subdomain(annotationPrefix); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid annotation prefix %q: %s", annotationPrefix, strings.Join(errs, ", "))
+	}
+
+	// The managed-by value also prefixes the names of the generated resources
+	if errs := validation.IsDNS1123Label(managedBy); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid managed-by %q: %s", managedBy, strings.Join(errs, ", "))
+	}
+
+	keys := map[string]string{}
+	for _, name := range prefixedNames {
+		keys[name] = annotationPrefix + "/" + name[strings.Index(name, "/")+1:]
+	}
+
+	return &Identity{
+		controllerName: controllerName,
+		managedBy:      managedBy,
+		keys:           keys,
+	}, nil
+}
+
+// DefaultIdentity returns the identity of the controller when none is configured.
+func DefaultIdentity() *Identity {
+	id, err := NewIdentity(DefaultControllerName, DefaultAnnotationPrefix, DefaultManagedBy)
+	if err != nil {
+		panic(err)
+	}
+
+	return id
+}
+
+// key returns the key under which the controller reads the annotation, or sets the label, of the default key.
+// Keys which are not under the annotation prefix, such as the ingress class annotation, are returned as is.
+func (id *Identity) key(name string) string {
+	if key, ok := id.keys[name]; ok {
+		return key
+	}
+
+	return name
+}
+
+// isManagedByController determines if a generated resource is managed by this instance of the controller.
+func (id *Identity) isManagedByController(objectLabels map[string]string) bool {
+	return objectLabels[managedByLabel] == id.managedBy
+}
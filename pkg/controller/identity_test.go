@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewIdentity(t *testing.T) {
+	tests := []struct {
+		name             string
+		controllerName   string
+		annotationPrefix string
+		managedBy        string
+		err              bool
+	}{
+		{
+			name:             "defaults",
+			controllerName:   DefaultControllerName,
+			annotationPrefix: DefaultAnnotationPrefix,
+			managedBy:        DefaultManagedBy,
+		},
+		{
+			name:             "second instance",
+			controllerName:   "example.com/internal-istio-controller",
+			annotationPrefix: "internal.example.com",
+			managedBy:        "internal-istio-controller",
+		},
+		{
+			name:             "controller name without a domain",
+			controllerName:   "ingress-istio-controller",
+			annotationPrefix: DefaultAnnotationPrefix,
+			managedBy:        DefaultManagedBy,
+			err:              true,
+		},
+		{
+			name:             "invalid annotation prefix",
+			controllerName:   DefaultControllerName,
+			annotationPrefix: "Internal_Example",
+			managedBy:        DefaultManagedBy,
+			err:              true,
+		},
+		{
+			name:             "managed-by is not a label",
+			controllerName:   DefaultControllerName,
+			annotationPrefix: DefaultAnnotationPrefix,
+			managedBy:        "internal.istio.controller",
+			err:              true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewIdentity(test.controllerName, test.annotationPrefix, test.managedBy)
+			if (err != nil) != test.err {
+				t.Errorf("expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestIdentityKeys(t *testing.T) {
+	id, err := NewIdentity("example.com/internal-istio-controller", "internal.example.com", "internal-istio-controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "annotation", key: GatewaysAnnotation, want: "internal.example.com/gateways"},
+		{name: "gateway annotation", key: StatusNodeSelectorAnnotation, want: "internal.example.com/status-node-selector"},
+		{name: "label", key: IngressNameLabel, want: "internal.example.com/ingress-name"},
+		{name: "annotation of another prefix", key: IngressClassAnnotation, want: IngressClassAnnotation},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := id.key(test.key); got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+
+	// The resources generated by the default instance are left to it
+	object := &metav1.ObjectMeta{Labels: DefaultIdentity().ingressReferenceLabels("app", "web")}
+	if _, _, ok := id.getIngressReference(object); ok {
+		t.Errorf("expected the resource of the default instance not to reference an ingress of the second instance")
+	}
+
+	object = &metav1.ObjectMeta{Labels: id.ingressReferenceLabels("app", "web")}
+	if namespace, name, ok := id.getIngressReference(object); !ok || namespace != "app" || name != "web" {
+		t.Errorf("expected the resource to reference \"app/web\", got %q, %q, %v", namespace, name, ok)
+	}
+}
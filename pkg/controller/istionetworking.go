@@ -0,0 +1,503 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istio "istio.io/client-go/pkg/clientset/versioned"
+	istioinformers "istio.io/client-go/pkg/informers/externalversions"
+	istionetworkinginformers "istio.io/client-go/pkg/informers/externalversions/networking/v1beta1"
+	istionetworkinglisters "istio.io/client-go/pkg/listers/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Versions of the Istio networking API which can be watched and written by the controller.
+const (
+	IstioNetworkingV1alpha3 = "v1alpha3"
+	IstioNetworkingV1beta1  = "v1beta1"
+	IstioNetworkingV1       = "v1"
+)
+
+// The Istio networking API group.
+const istioNetworkingGroup = "networking.istio.io"
+
+// The supported versions, in order of preference.
+var istioNetworkingVersions = []string{IstioNetworkingV1, IstioNetworkingV1beta1, IstioNetworkingV1alpha3}
+
+// DetectIstioNetworkingVersion returns the most recent version
+// of the Istio networking API served by the API server.
+func DetectIstioNetworkingVersion(client discovery.DiscoveryInterface) (string, error) {
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return "", err
+	}
+
+	for _, group := range groups.Groups {
+		if group.Name != istioNetworkingGroup {
+			continue
+		}
+
+		for _, version := range istioNetworkingVersions {
+			for _, served := range group.Versions {
+				if served.Version == version {
+					return version, nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no supported version of the %s API is served", istioNetworkingGroup)
+}
+
+// ValidIstioNetworkingVersion determines if the version of the Istio networking API is supported.
+func ValidIstioNetworkingVersion(version string) bool {
+	return stringInArray(version, istioNetworkingVersions)
+}
+
+// The controller works with the v1beta1 representation of the Istio networking resources.
+// As the schemas of the resources are identical across the versions of the API,
+// other versions are accessed through the dynamic client and converted to and from v1beta1.
+
+// IstioNetworkingInformers provides the informers of the Istio networking resources
+// for the selected version of the API.
+type IstioNetworkingInformers struct {
+	version        string
+	istioFactory   istioinformers.SharedInformerFactory
+	dynamicFactory dynamicinformer.DynamicSharedInformerFactory
+}
+
+// NewIstioNetworkingInformers creates the informers of the Istio networking resources for the version.
+func NewIstioNetworkingInformers(version string, istioFactory istioinformers.SharedInformerFactory, dynamicFactory dynamicinformer.DynamicSharedInformerFactory) *IstioNetworkingInformers {
+	return &IstioNetworkingInformers{
+		version:        version,
+		istioFactory:   istioFactory,
+		dynamicFactory: dynamicFactory,
+	}
+}
+
+// VirtualServices returns the informer for VirtualServices.
+func (i *IstioNetworkingInformers) VirtualServices() istionetworkinginformers.VirtualServiceInformer {
+	if i.version == IstioNetworkingV1beta1 {
+		return i.istioFactory.Networking().V1beta1().VirtualServices()
+	}
+
+	return &virtualServiceInformer{i.dynamicFactory.ForResource(istioNetworkingResource(i.version, "virtualservices"))}
+}
+
+// Gateways returns the informer for Gateways.
+func (i *IstioNetworkingInformers) Gateways() istionetworkinginformers.GatewayInformer {
+	if i.version == IstioNetworkingV1beta1 {
+		return i.istioFactory.Networking().V1beta1().Gateways()
+	}
+
+	return &gatewayInformer{i.dynamicFactory.ForResource(istioNetworkingResource(i.version, "gateways"))}
+}
+
+// ServiceEntries returns the informer for ServiceEntries.
+func (i *IstioNetworkingInformers) ServiceEntries() istionetworkinginformers.ServiceEntryInformer {
+	if i.version == IstioNetworkingV1beta1 {
+		return i.istioFactory.Networking().V1beta1().ServiceEntries()
+	}
+
+	return &serviceEntryInformer{i.dynamicFactory.ForResource(istioNetworkingResource(i.version, "serviceentries"))}
+}
+
+// DestinationRules returns the informer for DestinationRules.
+func (i *IstioNetworkingInformers) DestinationRules() istionetworkinginformers.DestinationRuleInformer {
+	if i.version == IstioNetworkingV1beta1 {
+		return i.istioFactory.Networking().V1beta1().DestinationRules()
+	}
+
+	return &destinationRuleInformer{i.dynamicFactory.ForResource(istioNetworkingResource(i.version, "destinationrules"))}
+}
+
+type virtualServiceInformer struct{ informer informers.GenericInformer }
+
+func (i *virtualServiceInformer) Informer() cache.SharedIndexInformer {
+	return i.informer.Informer()
+}
+
+func (i *virtualServiceInformer) Lister() istionetworkinglisters.VirtualServiceLister {
+	return &virtualServiceLister{newConvertingLister[istionetworkingv1beta1.VirtualService](i.informer.Informer().GetIndexer(), "virtualservices")}
+}
+
+type virtualServiceLister struct {
+	*convertingLister[istionetworkingv1beta1.VirtualService]
+}
+
+func (l *virtualServiceLister) VirtualServices(namespace string) istionetworkinglisters.VirtualServiceNamespaceLister {
+	return l.namespaced(namespace)
+}
+
+type gatewayInformer struct{ informer informers.GenericInformer }
+
+func (i *gatewayInformer) Informer() cache.SharedIndexInformer {
+	return i.informer.Informer()
+}
+
+func (i *gatewayInformer) Lister() istionetworkinglisters.GatewayLister {
+	return &gatewayLister{newConvertingLister[istionetworkingv1beta1.Gateway](i.informer.Informer().GetIndexer(), "gateways")}
+}
+
+type gatewayLister struct {
+	*convertingLister[istionetworkingv1beta1.Gateway]
+}
+
+func (l *gatewayLister) Gateways(namespace string) istionetworkinglisters.GatewayNamespaceLister {
+	return l.namespaced(namespace)
+}
+
+type serviceEntryInformer struct{ informer informers.GenericInformer }
+
+func (i *serviceEntryInformer) Informer() cache.SharedIndexInformer {
+	return i.informer.Informer()
+}
+
+func (i *serviceEntryInformer) Lister() istionetworkinglisters.ServiceEntryLister {
+	return &serviceEntryLister{newConvertingLister[istionetworkingv1beta1.ServiceEntry](i.informer.Informer().GetIndexer(), "serviceentries")}
+}
+
+type serviceEntryLister struct {
+	*convertingLister[istionetworkingv1beta1.ServiceEntry]
+}
+
+func (l *serviceEntryLister) ServiceEntries(namespace string) istionetworkinglisters.ServiceEntryNamespaceLister {
+	return l.namespaced(namespace)
+}
+
+type destinationRuleInformer struct{ informer informers.GenericInformer }
+
+func (i *destinationRuleInformer) Informer() cache.SharedIndexInformer {
+	return i.informer.Informer()
+}
+
+func (i *destinationRuleInformer) Lister() istionetworkinglisters.DestinationRuleLister {
+	return &destinationRuleLister{newConvertingLister[istionetworkingv1beta1.DestinationRule](i.informer.Informer().GetIndexer(), "destinationrules")}
+}
+
+type destinationRuleLister struct {
+	*convertingLister[istionetworkingv1beta1.DestinationRule]
+}
+
+func (l *destinationRuleLister) DestinationRules(namespace string) istionetworkinglisters.DestinationRuleNamespaceLister {
+	return l.namespaced(namespace)
+}
+
+// convertingLister lists the unstructured objects of an informer as their v1beta1 representation.
+// As the objects returned by listers must not be modified, the conversions are cached
+// until the resource version of the objects changes.
+type convertingLister[T any] struct {
+	indexer  cache.Indexer
+	resource string
+
+	lock      sync.Mutex
+	converted map[string]convertedObject[T]
+}
+
+type convertedObject[T any] struct {
+	resourceVersion string
+	obj             *T
+}
+
+func newConvertingLister[T any](indexer cache.Indexer, resource string) *convertingLister[T] {
+	return &convertingLister[T]{indexer: indexer, resource: resource, converted: map[string]convertedObject[T]{}}
+}
+
+func (l *convertingLister[T]) List(selector labels.Selector) (ret []*T, err error) {
+	ret, err = l.list(cache.ListAll, selector)
+
+	// The conversions of the deleted objects are dropped when all of the objects are listed
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for key := range l.converted {
+		if _, exists, _ := l.indexer.GetByKey(key); !exists {
+			delete(l.converted, key)
+		}
+	}
+
+	return ret, err
+}
+
+// convert returns the v1beta1 representation of an object of the informer.
+func (l *convertingLister[T]) convert(obj interface{}) (*T, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fromUnstructured[T](obj)
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(u)
+	if err != nil {
+		return nil, err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if converted, ok := l.converted[key]; ok && converted.resourceVersion == u.GetResourceVersion() {
+		return converted.obj, nil
+	}
+
+	out, err := fromUnstructured[T](u)
+	if err != nil {
+		return nil, err
+	}
+	l.converted[key] = convertedObject[T]{resourceVersion: u.GetResourceVersion(), obj: out}
+
+	return out, nil
+}
+
+func (l *convertingLister[T]) namespaced(namespace string) *convertingNamespaceLister[T] {
+	return &convertingNamespaceLister[T]{lister: l, namespace: namespace}
+}
+
+func (l *convertingLister[T]) list(listFunc func(cache.Store, labels.Selector, cache.AppendFunc) error, selector labels.Selector) (ret []*T, err error) {
+	var convertErr error
+	err = listFunc(l.indexer, selector, func(m interface{}) {
+		obj, err := l.convert(m)
+		if err != nil {
+			convertErr = err
+			return
+		}
+		ret = append(ret, obj)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, convertErr
+}
+
+type convertingNamespaceLister[T any] struct {
+	lister    *convertingLister[T]
+	namespace string
+}
+
+func (l *convertingNamespaceLister[T]) List(selector labels.Selector) (ret []*T, err error) {
+	return l.lister.list(func(store cache.Store, selector labels.Selector, appendFn cache.AppendFunc) error {
+		return cache.ListAllByNamespace(l.lister.indexer, l.namespace, selector, appendFn)
+	}, selector)
+}
+
+func (l *convertingNamespaceLister[T]) Get(name string) (*T, error) {
+	obj, exists, err := l.lister.indexer.GetByKey(l.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(schema.GroupResource{Group: istioNetworkingGroup, Resource: l.lister.resource}, name)
+	}
+
+	return l.lister.convert(obj)
+}
+
+// istioNetworkingClient writes the Istio networking resources of a namespace.
+// The typed clients, including those of the other Istio APIs, satisfy this interface.
+type istioNetworkingClient[T any] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*T, error)
+	Create(ctx context.Context, obj *T, opts metav1.CreateOptions) (*T, error)
+	Update(ctx context.Context, obj *T, opts metav1.UpdateOptions) (*T, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*T, error)
+}
+
+// istioNetworking provides the clients of the Istio networking resources for the selected version of the API.
+type istioNetworking struct {
+	version          string
+	istioclientset   istio.Interface
+	dynamicclientset dynamic.Interface
+	// Field manager of the controller, its managed-by value
+	fieldManager string
+}
+
+func (n *istioNetworking) VirtualServices(namespace string) istioNetworkingClient[istionetworkingv1beta1.VirtualService] {
+	if n.version == IstioNetworkingV1beta1 {
+		return n.istioclientset.NetworkingV1beta1().VirtualServices(namespace)
+	}
+
+	return &dynamicIstioNetworkingClient[istionetworkingv1beta1.VirtualService]{n.dynamicclientset.Resource(istioNetworkingResource(n.version, "virtualservices")).Namespace(namespace), n.version, "VirtualService"}
+}
+
+func (n *istioNetworking) Gateways(namespace string) istioNetworkingClient[istionetworkingv1beta1.Gateway] {
+	if n.version == IstioNetworkingV1beta1 {
+		return n.istioclientset.NetworkingV1beta1().Gateways(namespace)
+	}
+
+	return &dynamicIstioNetworkingClient[istionetworkingv1beta1.Gateway]{n.dynamicclientset.Resource(istioNetworkingResource(n.version, "gateways")).Namespace(namespace), n.version, "Gateway"}
+}
+
+func (n *istioNetworking) ServiceEntries(namespace string) istioNetworkingClient[istionetworkingv1beta1.ServiceEntry] {
+	if n.version == IstioNetworkingV1beta1 {
+		return n.istioclientset.NetworkingV1beta1().ServiceEntries(namespace)
+	}
+
+	return &dynamicIstioNetworkingClient[istionetworkingv1beta1.ServiceEntry]{n.dynamicclientset.Resource(istioNetworkingResource(n.version, "serviceentries")).Namespace(namespace), n.version, "ServiceEntry"}
+}
+
+func (n *istioNetworking) DestinationRules(namespace string) istioNetworkingClient[istionetworkingv1beta1.DestinationRule] {
+	if n.version == IstioNetworkingV1beta1 {
+		return n.istioclientset.NetworkingV1beta1().DestinationRules(namespace)
+	}
+
+	return &dynamicIstioNetworkingClient[istionetworkingv1beta1.DestinationRule]{n.dynamicclientset.Resource(istioNetworkingResource(n.version, "destinationrules")).Namespace(namespace), n.version, "DestinationRule"}
+}
+
+// dynamicIstioNetworkingClient writes v1beta1 objects to another version of the API through the dynamic client.
+type dynamicIstioNetworkingClient[T any] struct {
+	client  dynamic.ResourceInterface
+	version string
+	kind    string
+}
+
+func (d *dynamicIstioNetworkingClient[T]) Get(ctx context.Context, name string, opts metav1.GetOptions) (*T, error) {
+	u, err := d.client.Get(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromUnstructured[T](u)
+}
+
+func (d *dynamicIstioNetworkingClient[T]) Create(ctx context.Context, obj *T, opts metav1.CreateOptions) (*T, error) {
+	u, err := toUnstructured(obj, d.version, d.kind)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err = d.client.Create(ctx, u, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromUnstructured[T](u)
+}
+
+func (d *dynamicIstioNetworkingClient[T]) Update(ctx context.Context, obj *T, opts metav1.UpdateOptions) (*T, error) {
+	u, err := toUnstructured(obj, d.version, d.kind)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err = d.client.Update(ctx, u, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromUnstructured[T](u)
+}
+
+func (d *dynamicIstioNetworkingClient[T]) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return d.client.Delete(ctx, name, opts)
+}
+
+func (d *dynamicIstioNetworkingClient[T]) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*T, error) {
+	u, err := d.client.Patch(ctx, name, pt, data, opts, subresources...)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromUnstructured[T](u)
+}
+
+// applyVirtualService applies the VirtualService through server-side apply
+// under the field manager of the controller. Only the fields set on vs are
+// owned by the controller, so the fields of other managers are left untouched.
+func (n *istioNetworking) applyVirtualService(ctx context.Context, vs *istionetworkingv1beta1.VirtualService, dryRun bool) (*istionetworkingv1beta1.VirtualService, error) {
+	u, err := toUnstructured(vs, n.version, "VirtualService")
+	if err != nil {
+		return nil, err
+	}
+
+	// The server manages these fields
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "status")
+
+	data, err := json.Marshal(u.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	force := true
+	opts := metav1.PatchOptions{FieldManager: n.fieldManager, Force: &force}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	return n.VirtualServices(vs.Namespace).Patch(ctx, vs.Name, types.ApplyPatchType, data, opts)
+}
+
+// upgradeVirtualServiceManagedFields transfers the fields of the VirtualService owned by the legacy
+// managers of the controller to its apply manager, so that they are removed when no longer applied.
+// The patch fails if the VirtualService changed since it was read.
+func (n *istioNetworking) upgradeVirtualServiceManagedFields(ctx context.Context, vs *istionetworkingv1beta1.VirtualService) (*istionetworkingv1beta1.VirtualService, error) {
+	managedFields, err := upgradeManagedFields(n.fieldManager, vs.ManagedFields)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal([]map[string]interface{}{
+		{"op": "test", "path": "/metadata/resourceVersion", "value": vs.ResourceVersion},
+		{"op": "replace", "path": "/metadata/managedFields", "value": managedFields},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return n.VirtualServices(vs.Namespace).Patch(ctx, vs.Name, types.JSONPatchType, data, metav1.PatchOptions{})
+}
+
+// Returns the resource of the Istio networking API for the version.
+func istioNetworkingResource(version, resource string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: istioNetworkingGroup, Version: version, Resource: resource}
+}
+
+// Converts an unstructured object into its v1beta1 representation.
+// The specs are converted through their JSON representation, which supports the oneof fields.
+func fromUnstructured[T any](obj interface{}) (*T, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("expected unstructured object but got %T", obj)
+	}
+
+	data, err := json.Marshal(u.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(T)
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Converts a v1beta1 object into an unstructured object of the version.
+func toUnstructured(obj interface{}, version, kind string) (*unstructured.Unstructured, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(data, &u.Object); err != nil {
+		return nil, err
+	}
+
+	u.SetAPIVersion(schema.GroupVersion{Group: istioNetworkingGroup, Version: version}.String())
+	u.SetKind(kind)
+
+	return u, nil
+}
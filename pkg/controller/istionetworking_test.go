@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"testing"
+
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+func unstructuredGateway(namespace, name, resourceVersion string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1",
+		"kind":       "Gateway",
+		"metadata": map[string]interface{}{
+			"namespace":       namespace,
+			"name":            name,
+			"resourceVersion": resourceVersion,
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{"istio": "ingressgateway"},
+		},
+	}}
+}
+
+func TestConvertingLister(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	lister := newConvertingLister[istionetworkingv1beta1.Gateway](indexer, "gateways")
+
+	if err := indexer.Add(unstructuredGateway("istio-system", "ingressgateway", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := lister.namespaced("istio-system").Get("ingressgateway")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Spec.Selector["istio"] != "ingressgateway" {
+		t.Errorf("unexpected selector %v", first.Spec.Selector)
+	}
+
+	// Unchanged objects are not converted again
+	listed, err := lister.List(labels.Everything())
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("expected 1 gateway, got %d (%v)", len(listed), err)
+	}
+	if listed[0] != first {
+		t.Errorf("expected the cached conversion of the unchanged gateway")
+	}
+
+	// Updated objects are converted again
+	if err := indexer.Update(unstructuredGateway("istio-system", "ingressgateway", "2")); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := lister.namespaced("istio-system").Get("ingressgateway")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated == first || updated.ResourceVersion != "2" {
+		t.Errorf("expected the conversion of the updated gateway, got resource version %q", updated.ResourceVersion)
+	}
+
+	// Deleted objects are dropped from the cache
+	if err := indexer.Delete(unstructuredGateway("istio-system", "ingressgateway", "2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lister.List(labels.Everything()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lister.converted) != 0 {
+		t.Errorf("expected the conversions of the deleted gateways to be dropped, got %d", len(lister.converted))
+	}
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// Log formats of the controller.
+const (
+	TextLogFormat = "text"
+	JSONLogFormat = "json"
+)
+
+// reconcileLog identifies the lines logged during the reconcile of an Ingress.
+type reconcileLog struct {
+	id             string
+	virtualService string
+}
+
+// reconcileLogs tracks the reconciles in progress by Ingress key.
+// The workqueue never processes the same key concurrently,
+// so the key identifies a single reconcile.
+type reconcileLogs struct {
+	lock       sync.Mutex
+	reconciles map[string]*reconcileLog
+}
+
+// begin starts tracking the reconcile of the Ingress under a new ID.
+func (l *reconcileLogs) begin(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.reconciles == nil {
+		l.reconciles = map[string]*reconcileLog{}
+	}
+
+	l.reconciles[key] = &reconcileLog{id: string(uuid.NewUUID())}
+}
+
+// end stops tracking the reconcile of the Ingress.
+func (l *reconcileLogs) end(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.reconciles, key)
+}
+
+// setVirtualService records the name of the VirtualService of the Ingress being reconciled.
+func (l *reconcileLogs) setVirtualService(key, name string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if r, ok := l.reconciles[key]; ok {
+		r.virtualService = name
+	}
+}
+
+// get returns the reconcile of the Ingress in progress, if any.
+func (l *reconcileLogs) get(key string) *reconcileLog {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if r, ok := l.reconciles[key]; ok {
+		rc := *r
+		return &rc
+	}
+
+	return nil
+}
+
+// logValues returns the key/value pairs identifying the Ingress and its reconcile in progress,
+// followed by the given key/value pairs, for use with klog.InfoS and klog.ErrorS.
+func (c *Controller) logValues(namespace, name string, keysAndValues ...interface{}) []interface{} {
+	values := []interface{}{"namespace", namespace, "name", name}
+
+	if r := c.reconcileLogs.get(fmt.Sprintf("%s/%s", namespace, name)); r != nil {
+		values = append(values, "reconcileID", r.id)
+		if r.virtualService != "" {
+			values = append(values, "virtualService", r.virtualService)
+		}
+	}
+
+	return append(values, keysAndValues...)
+}
+
+// jsonLogger is a logr.Logger writing one JSON object per line.
+type jsonLogger struct {
+	lock   *sync.Mutex
+	out    io.Writer
+	level  int
+	name   string
+	values []interface{}
+}
+
+// NewJSONLogger returns a logger writing one JSON object per line to the writer.
+// It is meant to back klog through klog.SetLogger.
+func NewJSONLogger(out io.Writer) logr.Logger {
+	return &jsonLogger{lock: &sync.Mutex{}, out: out}
+}
+
+func (l *jsonLogger) Enabled() bool {
+	return true
+}
+
+func (l *jsonLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.write("info", nil, msg, keysAndValues)
+}
+
+func (l *jsonLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.write("error", err, msg, keysAndValues)
+}
+
+func (l *jsonLogger) V(level int) logr.Logger {
+	nl := *l
+	nl.level = level
+	return &nl
+}
+
+func (l *jsonLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	nl := *l
+	nl.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return &nl
+}
+
+func (l *jsonLogger) WithName(name string) logr.Logger {
+	nl := *l
+	if nl.name != "" {
+		nl.name = nl.name + "." + name
+	} else {
+		nl.name = name
+	}
+	return &nl
+}
+
+func (l *jsonLogger) write(severity string, err error, msg string, keysAndValues []interface{}) {
+	entry := map[string]interface{}{
+		"ts":    time.Now().UTC().Format(time.RFC3339Nano),
+		"level": severity,
+		"msg":   strings.TrimSuffix(msg, "\n"),
+	}
+	if l.level > 0 {
+		entry["v"] = l.level
+	}
+	if l.name != "" {
+		entry["logger"] = l.name
+	}
+	if err != nil {
+		entry["err"] = err.Error()
+	}
+
+	kvs := append(append([]interface{}{}, l.values...), keysAndValues...)
+	for i := 0; i < len(kvs); i += 2 {
+		key := fmt.Sprint(kvs[i])
+		if i+1 >= len(kvs) {
+			entry[key] = "(MISSING)"
+			break
+		}
+
+		switch v := kvs[i+1].(type) {
+		case error:
+			entry[key] = v.Error()
+		case fmt.Stringer:
+			entry[key] = v.String()
+		default:
+			entry[key] = v
+		}
+	}
+
+	data, merr := json.Marshal(entry)
+	if merr != nil {
+		data, _ = json.Marshal(map[string]interface{}{"ts": entry["ts"], "level": "error", "msg": entry["msg"], "err": merr.Error()})
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.out.Write(append(data, '\n'))
+}
+
+// VerbosityHandler returns the handler reading and changing the verbosity of the logs at runtime.
+// GET returns the current verbosity and PUT sets it from the "v" query parameter.
+func VerbosityHandler(verbosity flag.Value) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			if err := verbosity.Set(r.URL.Query().Get("v")); err != nil {
+				http.Error(w, fmt.Sprintf("invalid verbosity: %v", err), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		fmt.Fprintln(w, verbosity.String())
+	})
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Before being applied, the VirtualServices were created and updated by the controller
+// under managers of the Update operation. The fields owned by these managers are not owned
+// by the apply manager, so they would be kept when removed from the generated VirtualService.
+// Their entries are merged into the entry of the apply manager, as kubectl does when
+// upgrading objects from client-side to server-side apply.
+// The manager of the helpers is the field manager of the controller, its managed-by value.
+
+// legacyFieldManagers returns the managers under which the controller created and updated objects.
+// Without a field manager, the server uses the product of the user agent.
+func legacyFieldManagers(manager string) []string {
+	return []string{manager, strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]}
+}
+
+func isLegacyManagedFieldsEntry(manager string, entry metav1.ManagedFieldsEntry) bool {
+	return entry.Operation == metav1.ManagedFieldsOperationUpdate && stringInArray(entry.Manager, legacyFieldManagers(manager))
+}
+
+func isAppliedManagedFieldsEntry(manager string, entry metav1.ManagedFieldsEntry) bool {
+	return entry.Operation == metav1.ManagedFieldsOperationApply && entry.Manager == manager
+}
+
+// hasLegacyManagedFields determines if fields are owned by the legacy managers of the controller.
+func hasLegacyManagedFields(manager string, entries []metav1.ManagedFieldsEntry) bool {
+	for _, entry := range entries {
+		if isLegacyManagedFieldsEntry(manager, entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// upgradeManagedFields returns the managed fields with the fields of the legacy managers
+// of the controller owned by its apply manager.
+func upgradeManagedFields(manager string, entries []metav1.ManagedFieldsEntry) ([]metav1.ManagedFieldsEntry, error) {
+	upgraded := []metav1.ManagedFieldsEntry{}
+	var applied *metav1.ManagedFieldsEntry
+	var legacy []metav1.ManagedFieldsEntry
+
+	for _, entry := range entries {
+		if isLegacyManagedFieldsEntry(manager, entry) {
+			legacy = append(legacy, entry)
+			continue
+		}
+
+		upgraded = append(upgraded, entry)
+		if isAppliedManagedFieldsEntry(manager, entry) {
+			applied = &upgraded[len(upgraded)-1]
+		}
+	}
+
+	if len(legacy) == 0 {
+		return entries, nil
+	}
+
+	if applied == nil {
+		upgraded = append(upgraded, metav1.ManagedFieldsEntry{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationApply,
+			APIVersion: legacy[0].APIVersion,
+			Time:       legacy[0].Time,
+			FieldsType: "FieldsV1",
+		})
+		applied = &upgraded[len(upgraded)-1]
+	}
+
+	fields := map[string]interface{}{}
+	for _, entry := range append([]metav1.ManagedFieldsEntry{*applied}, legacy...) {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+
+		entryFields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &entryFields); err != nil {
+			return nil, err
+		}
+		mergeFields(fields, entryFields)
+	}
+
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	applied.FieldsV1 = &metav1.FieldsV1{Raw: raw}
+
+	return upgraded, nil
+}
+
+// mergeFields adds the fields of the set src to the set dst.
+func mergeFields(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcChildren, _ := v.(map[string]interface{})
+		dstChildren, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dst[k] = srcChildren
+			if srcChildren == nil {
+				dst[k] = map[string]interface{}{}
+			}
+			continue
+		}
+
+		mergeFields(dstChildren, srcChildren)
+	}
+}
+
+// appliedKeys returns the keys of the map of the metadata owned by the apply manager of the controller,
+// such as "labels" or "annotations".
+func appliedKeys(manager string, entries []metav1.ManagedFieldsEntry, field string) []string {
+	keys := []string{}
+
+	for _, entry := range entries {
+		if !isAppliedManagedFieldsEntry(manager, entry) || entry.FieldsV1 == nil {
+			continue
+		}
+
+		fields := map[string]map[string]map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+
+		for key := range fields["f:metadata"]["f:"+field] {
+			if strings.HasPrefix(key, "f:") {
+				keys = append(keys, strings.TrimPrefix(key, "f:"))
+			}
+		}
+	}
+
+	return keys
+}
+
+// isAppliedSpecField determines if the field of the spec is owned by the apply manager of the controller.
+func isAppliedSpecField(manager string, entries []metav1.ManagedFieldsEntry, field string) bool {
+	for _, entry := range entries {
+		if !isAppliedManagedFieldsEntry(manager, entry) || entry.FieldsV1 == nil {
+			continue
+		}
+
+		fields := map[string]map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+
+		if _, ok := fields["f:spec"]["f:"+field]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appliedKeysRemoved determines if keys of the map of the metadata owned by the apply manager
+// of the controller are missing from the desired map, and would be removed by applying it.
+func appliedKeysRemoved(manager string, entries []metav1.ManagedFieldsEntry, field string, desired map[string]string) bool {
+	for _, key := range appliedKeys(manager, entries, field) {
+		if _, ok := desired[key]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func managedFieldsEntry(manager string, operation metav1.ManagedFieldsOperationType, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    manager,
+		Operation:  operation,
+		APIVersion: "networking.istio.io/v1beta1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func TestUpgradeManagedFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []metav1.ManagedFieldsEntry
+		// The fields of the managers, by manager and operation
+		fields map[string]string
+	}{
+		{
+			name: "created by the controller",
+			entries: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry(DefaultManagedBy, metav1.ManagedFieldsOperationUpdate, `{"f:metadata":{"f:labels":{".":{},"f:a":{}}},"f:spec":{"f:hosts":{}}}`),
+			},
+			fields: map[string]string{
+				DefaultManagedBy + " Apply": `{"f:metadata":{"f:labels":{".":{},"f:a":{}}},"f:spec":{"f:hosts":{}}}`,
+			},
+		},
+		{
+			name: "updated by the controller after being applied",
+			entries: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry(DefaultManagedBy, metav1.ManagedFieldsOperationApply, `{"f:metadata":{"f:labels":{"f:a":{}}}}`),
+				managedFieldsEntry(DefaultManagedBy, metav1.ManagedFieldsOperationUpdate, `{"f:metadata":{"f:labels":{"f:b":{}}}}`),
+			},
+			fields: map[string]string{
+				DefaultManagedBy + " Apply": `{"f:metadata":{"f:labels":{"f:a":{},"f:b":{}}}}`,
+			},
+		},
+		{
+			name: "fields of other managers are kept",
+			entries: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("kubectl", metav1.ManagedFieldsOperationUpdate, `{"f:metadata":{"f:labels":{"f:c":{}}}}`),
+				managedFieldsEntry(DefaultManagedBy, metav1.ManagedFieldsOperationApply, `{"f:metadata":{"f:labels":{"f:a":{}}}}`),
+			},
+			fields: map[string]string{
+				"kubectl Update":            `{"f:metadata":{"f:labels":{"f:c":{}}}}`,
+				DefaultManagedBy + " Apply": `{"f:metadata":{"f:labels":{"f:a":{}}}}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := upgradeManagedFields(DefaultManagedBy, test.entries)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if hasLegacyManagedFields(DefaultManagedBy, entries) {
+				t.Errorf("legacy managers remain in %v", entries)
+			}
+
+			fields := map[string]string{}
+			for _, entry := range entries {
+				fields[entry.Manager+" "+string(entry.Operation)] = string(entry.FieldsV1.Raw)
+			}
+
+			if diff := cmp.Diff(normalizeFields(t, test.fields), normalizeFields(t, fields)); diff != "" {
+				t.Errorf("unexpected fields (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// normalizeFields decodes the fields of the managers, so they compare regardless of the order of their keys.
+func normalizeFields(t *testing.T, fields map[string]string) map[string]interface{} {
+	normalized := map[string]interface{}{}
+	for manager, raw := range fields {
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			t.Fatalf("invalid fields of %s: %v", manager, err)
+		}
+		normalized[manager] = v
+	}
+
+	return normalized
+}
+
+func TestVirtualServiceChanged(t *testing.T) {
+	applied := []metav1.ManagedFieldsEntry{
+		managedFieldsEntry(DefaultManagedBy, metav1.ManagedFieldsOperationApply, `{"f:metadata":{"f:labels":{"f:a":{}},"f:annotations":{"f:x":{}}}}`),
+	}
+
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		newLabels map[string]string
+		changed   bool
+	}{
+		{
+			name:      "unchanged",
+			labels:    map[string]string{"a": "1"},
+			newLabels: map[string]string{"a": "1"},
+		},
+		{
+			name:      "label of another manager",
+			labels:    map[string]string{"a": "1", "b": "2"},
+			newLabels: map[string]string{"a": "1"},
+		},
+		{
+			name:      "label changed",
+			labels:    map[string]string{"a": "1"},
+			newLabels: map[string]string{"a": "2"},
+			changed:   true,
+		},
+		{
+			name:      "applied label removed",
+			labels:    map[string]string{"a": "1"},
+			newLabels: map[string]string{},
+			changed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vs := &istionetworkingv1beta1.VirtualService{ObjectMeta: metav1.ObjectMeta{
+				Labels:        test.labels,
+				Annotations:   map[string]string{"x": "1"},
+				ManagedFields: applied,
+			}}
+			nvs := &istionetworkingv1beta1.VirtualService{ObjectMeta: metav1.ObjectMeta{
+				Labels:      test.newLabels,
+				Annotations: map[string]string{"x": "1"},
+			}}
+
+			if changed := virtualServiceChanged(DefaultManagedBy, vs, nvs); changed != test.changed {
+				t.Errorf("expected changed to be %t, got %t", test.changed, changed)
+			}
+		})
+	}
+}
+
+func TestVirtualServiceSpecChanged(t *testing.T) {
+	route := func(name string) *v1beta1.HTTPRoute {
+		return &v1beta1.HTTPRoute{Name: name, Route: []*v1beta1.HTTPRouteDestination{{Destination: &v1beta1.Destination{Host: name}}}}
+	}
+	generated := v1beta1.VirtualService{
+		Hosts:    []string{"a.example.com"},
+		Gateways: []string{"istio-system/ingressgateway"},
+		Http:     []*v1beta1.HTTPRoute{route("web")},
+	}
+
+	tests := []struct {
+		name     string
+		spec     v1beta1.VirtualService
+		newSpec  v1beta1.VirtualService
+		managers []metav1.ManagedFieldsEntry
+		changed  bool
+	}{
+		{
+			name:    "unchanged",
+			spec:    generated,
+			newSpec: generated,
+		},
+		{
+			name: "tcp routes of another manager",
+			spec: v1beta1.VirtualService{
+				Hosts:    generated.Hosts,
+				Gateways: generated.Gateways,
+				Http:     generated.Http,
+				Tcp:      []*v1beta1.TCPRoute{{Route: []*v1beta1.RouteDestination{{Destination: &v1beta1.Destination{Host: "db"}}}}},
+			},
+			newSpec: generated,
+			managers: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry(DefaultManagedBy, metav1.ManagedFieldsOperationApply, `{"f:spec":{"f:hosts":{},"f:gateways":{},"f:http":{}}}`),
+				managedFieldsEntry("kubectl", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:tcp":{}}}`),
+			},
+		},
+		{
+			name: "exportTo of another manager",
+			spec: v1beta1.VirtualService{
+				Hosts:    generated.Hosts,
+				Gateways: generated.Gateways,
+				Http:     generated.Http,
+				ExportTo: []string{"."},
+			},
+			newSpec: generated,
+			managers: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry(DefaultManagedBy, metav1.ManagedFieldsOperationApply, `{"f:spec":{"f:hosts":{},"f:gateways":{},"f:http":{}}}`),
+				managedFieldsEntry("kubectl", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:exportTo":{}}}`),
+			},
+		},
+		{
+			name: "applied exportTo removed",
+			spec: v1beta1.VirtualService{
+				Hosts:    generated.Hosts,
+				Gateways: generated.Gateways,
+				Http:     generated.Http,
+				ExportTo: []string{"."},
+			},
+			newSpec: generated,
+			managers: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry(DefaultManagedBy, metav1.ManagedFieldsOperationApply, `{"f:spec":{"f:hosts":{},"f:gateways":{},"f:http":{},"f:exportTo":{}}}`),
+			},
+			changed: true,
+		},
+		{
+			name:    "no http routes",
+			spec:    v1beta1.VirtualService{Hosts: generated.Hosts, Gateways: generated.Gateways},
+			newSpec: v1beta1.VirtualService{Hosts: generated.Hosts, Gateways: generated.Gateways, Http: []*v1beta1.HTTPRoute{}},
+		},
+		{
+			name:    "http routes changed",
+			spec:    generated,
+			newSpec: v1beta1.VirtualService{Hosts: generated.Hosts, Gateways: generated.Gateways, Http: []*v1beta1.HTTPRoute{route("api")}},
+			changed: true,
+		},
+		{
+			name:    "hosts changed",
+			spec:    generated,
+			newSpec: v1beta1.VirtualService{Hosts: []string{"b.example.com"}, Gateways: generated.Gateways, Http: generated.Http},
+			changed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vs := &istionetworkingv1beta1.VirtualService{ObjectMeta: metav1.ObjectMeta{ManagedFields: test.managers}, Spec: test.spec}
+			nvs := &istionetworkingv1beta1.VirtualService{Spec: test.newSpec}
+
+			if changed := virtualServiceChanged(DefaultManagedBy, vs, nvs); changed != test.changed {
+				t.Errorf("expected changed to be %t, got %t", test.changed, changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const metricsNamespace = "ingress_istio_controller"
+
+// Results of the reconciliation of an Ingress.
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_total",
+		Help:      "Number of reconciliations of Ingresses by result.",
+	}, []string{"result"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of the reconciliations of Ingresses by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	virtualServiceOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "virtualservice_operations_total",
+		Help:      "Number of VirtualServices created, updated and deleted.",
+	}, []string{"operation"})
+
+	statusUpdatesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "status_updates_total",
+		Help:      "Number of updates of the status of Ingresses by result.",
+	}, []string{"result"})
+
+	ingressesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "ingresses",
+		Help:      "Number of Ingresses handled and ignored by the controller.",
+	}, []string{"state"})
+
+	configReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "config_reloads_total",
+		Help:      "Number of reloads of the configuration file by result.",
+	}, []string{"result"})
+
+	configLastReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "config_last_reload_successful",
+		Help:      "Whether the last reload of the configuration file was successful (1) or rejected (0).",
+	})
+
+	dryRunChangesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dry_run_changes",
+		Help:      "Number of changes the controller would make to the cluster outside of the dry-run mode.",
+	}, []string{"kind", "operation"})
+
+	webhookAdmissionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_admissions_total",
+		Help:      "Number of admission reviews of Ingresses by the validating webhook by result.",
+	}, []string{"result"})
+
+	leaderGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "leader",
+		Help:      "Whether this replica holds the leader lease (1) or not (0).",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		reconcileTotal,
+		reconcileDuration,
+		virtualServiceOperationsTotal,
+		statusUpdatesTotal,
+		ingressesGauge,
+		configReloadsTotal,
+		configLastReloadSuccessful,
+		dryRunChangesGauge,
+		webhookAdmissionsTotal,
+		leaderGauge,
+	)
+
+	workqueue.SetProvider(&workqueueMetricsProvider{})
+}
+
+// MetricsHandler returns the handler serving the metrics of the controller.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// RecordConfigReload records the result of a reload of the configuration file.
+func RecordConfigReload(err error) {
+	if err != nil {
+		configReloadsTotal.WithLabelValues(resultError).Inc()
+		configLastReloadSuccessful.Set(0)
+	} else {
+		configReloadsTotal.WithLabelValues(resultSuccess).Inc()
+		configLastReloadSuccessful.Set(1)
+	}
+}
+
+// ingressStates tracks which Ingresses are handled and ignored by the controller.
+type ingressStates struct {
+	lock   sync.Mutex
+	states map[string]string
+}
+
+// set records the state of the Ingress, or forgets the Ingress if the state is empty.
+func (s *ingressStates) set(key, state string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.states == nil {
+		s.states = map[string]string{}
+	}
+
+	if state == "" {
+		delete(s.states, key)
+	} else {
+		s.states[key] = state
+	}
+
+	counts := map[string]float64{"handled": 0, "ignored": 0}
+	for _, st := range s.states {
+		counts[st]++
+	}
+
+	for st, count := range counts {
+		ingressesGauge.WithLabelValues(st).Set(count)
+	}
+}
+
+// informerSyncCollector reports whether the informer caches of the controller have synced.
+type informerSyncCollector struct {
+	desc      *prometheus.Desc
+	informers map[string]cache.InformerSynced
+}
+
+func newInformerSyncCollector(informers map[string]cache.InformerSynced) *informerSyncCollector {
+	return &informerSyncCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "informer_synced"),
+			"Whether the informer cache has synced (1) or not (0).",
+			[]string{"informer"}, nil),
+		informers: informers,
+	}
+}
+
+func (c *informerSyncCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *informerSyncCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, synced := range c.informers {
+		value := 0.0
+		if synced() {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, name)
+	}
+}
+
+// workqueueMetricsProvider exposes the metrics of the workqueues through Prometheus.
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return registerMetric(prometheus.NewGauge(prometheus.GaugeOpts{
+		Subsystem:   "workqueue",
+		Name:        "depth",
+		Help:        "Current depth of the workqueue.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})).(prometheus.Gauge)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem:   "workqueue",
+		Name:        "adds_total",
+		Help:        "Number of adds handled by the workqueue.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})).(prometheus.Counter)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return registerMetric(prometheus.NewHistogram(prometheus.HistogramOpts{
+		Subsystem:   "workqueue",
+		Name:        "queue_duration_seconds",
+		Help:        "How long in seconds an item stays in the workqueue before being requested.",
+		ConstLabels: prometheus.Labels{"name": name},
+		Buckets:     prometheus.ExponentialBuckets(10e-9, 10, 10),
+	})).(prometheus.Histogram)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return registerMetric(prometheus.NewHistogram(prometheus.HistogramOpts{
+		Subsystem:   "workqueue",
+		Name:        "work_duration_seconds",
+		Help:        "How long in seconds processing an item from the workqueue takes.",
+		ConstLabels: prometheus.Labels{"name": name},
+		Buckets:     prometheus.ExponentialBuckets(10e-9, 10, 10),
+	})).(prometheus.Histogram)
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return registerMetric(prometheus.NewGauge(prometheus.GaugeOpts{
+		Subsystem:   "workqueue",
+		Name:        "unfinished_work_seconds",
+		Help:        "How many seconds of work has been done that is in progress and hasn't been observed by work_duration.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})).(prometheus.Gauge)
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return registerMetric(prometheus.NewGauge(prometheus.GaugeOpts{
+		Subsystem:   "workqueue",
+		Name:        "longest_running_processor_seconds",
+		Help:        "How many seconds has the longest running processor for the workqueue been running.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})).(prometheus.Gauge)
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return registerMetric(prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem:   "workqueue",
+		Name:        "retries_total",
+		Help:        "Number of retries handled by the workqueue.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})).(prometheus.Counter)
+}
+
+// registerMetric registers the metric, returning the existing metric if it was already registered.
+func registerMetric(c prometheus.Collector) prometheus.Collector {
+	if err := metricsRegistry.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+
+	return c
+}
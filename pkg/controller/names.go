@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// virtualServiceName returns the deterministic name of the VirtualService of the Ingress.
+func virtualServiceName(ingressName string) string {
+	return truncateName(fmt.Sprintf("%s-vs", ingressName), validation.DNS1123SubdomainMaxLength)
+}
+
+// generatedName joins the parts of the name of a generated resource,
+// truncated to the maximum length of the names of resources.
+func generatedName(parts ...string) string {
+	return truncateName(strings.Join(parts, "-"), validation.DNS1123SubdomainMaxLength)
+}
+
+// truncateName truncates the name to the maximum length, replacing
+// the end of the name with its hash to keep the truncated names unique.
+// The separators left at the end of the truncated name are removed, so that
+// truncated names and label values remain valid.
+func truncateName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	hash := fmt.Sprintf("%08x", h.Sum32())
+
+	return fmt.Sprintf("%s-%s", strings.TrimRight(name[:max-len(hash)-1], "-_."), hash)
+}
+
+// findExistingVirtualServicesForIngress returns all of the VirtualServices owned by the Ingress,
+// oldest first. Multiple VirtualServices are owned when replicas raced to create them,
+// or when their names were generated and are being migrated.
+func (c *Controller) findExistingVirtualServicesForIngress(ingress *networkingv1.Ingress) ([]*istionetworkingv1beta1.VirtualService, error) {
+	vss, err := c.virtualServicesListers.VirtualServices(ingress.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	owned := []*istionetworkingv1beta1.VirtualService{}
+	for _, vs := range vss {
+		// VirtualServices created by other instances of the controller are left alone
+		if metav1.IsControlledBy(vs, ingress) && c.identity.isManagedByController(vs.Labels) {
+			owned = append(owned, vs)
+		}
+	}
+
+	sortVirtualServicesByAge(owned)
+
+	return owned, nil
+}
+
+// removeDuplicateVirtualServices removes the VirtualServices owned by the Ingress other than vs.
+func (c *Controller) removeDuplicateVirtualServices(ingress *networkingv1.Ingress, vs *istionetworkingv1beta1.VirtualService) error {
+	owned, err := c.findExistingVirtualServicesForIngress(ingress)
+	if err != nil {
+		return err
+	}
+
+	for _, ovs := range owned {
+		if ovs.Name == vs.Name {
+			continue
+		}
+
+		klog.InfoS("removing duplicate virtualservice", c.logValues(ingress.Namespace, ingress.Name, "duplicate", ovs.Name)...)
+		err = c.istioNetworking.VirtualServices(ovs.Namespace).Delete(context.Background(), ovs.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		virtualServiceOperationsTotal.WithLabelValues("delete").Inc()
+		c.recorder.Eventf(ingress, corev1.EventTypeNormal, ReasonVirtualServiceDeleted, "Deleted duplicate virtualservice %q", ovs.Name)
+	}
+
+	return nil
+}
+
+// migrateVirtualServiceNames renames the VirtualServices generated with random names
+// to their deterministic names and removes the duplicates owned by the same Ingress.
+// The oldest VirtualService of an Ingress is copied to the deterministic name, unless
+// a VirtualService of that name already exists, and the others are removed once the
+// root VirtualService delegates to the new name. The VirtualServices of deleted Ingresses
+// are left to the garbage collector.
+func (c *Controller) migrateVirtualServiceNames() error {
+	ctx := context.Background()
+
+	vss, err := c.virtualServicesListers.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	// Group the VirtualServices by their owning Ingress
+	owned := map[types.UID][]*istionetworkingv1beta1.VirtualService{}
+	owners := map[types.UID]*metav1.OwnerReference{}
+	for _, vs := range vss {
+		ownerRef := metav1.GetControllerOf(vs)
+		if ownerRef == nil || ownerRef.Kind != "Ingress" || !c.identity.isManagedByController(vs.Labels) {
+			continue
+		}
+
+		owned[ownerRef.UID] = append(owned[ownerRef.UID], vs)
+		owners[ownerRef.UID] = ownerRef
+	}
+
+	for uid, vss := range owned {
+		sortVirtualServicesByAge(vss)
+
+		name := virtualServiceName(owners[uid].Name)
+
+		ingress, err := c.ingressesLister.Ingresses(vss[0].Namespace).Get(owners[uid].Name)
+		if errors.IsNotFound(err) || (err == nil && ingress.UID != uid) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		// Adopted VirtualServices keep their name
+		adoptName, _ := getAdoptVirtualServiceName(c.identity, ingress)
+		var current *istionetworkingv1beta1.VirtualService
+		for _, vs := range vss {
+			if vs.Name == name || vs.Name == adoptName {
+				current = vs
+				break
+			}
+		}
+
+		if current == nil {
+			klog.InfoS("renaming virtualservice", "virtualService", klog.KObj(vss[0]), "newName", name)
+
+			nvs := &istionetworkingv1beta1.VirtualService{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            name,
+					Namespace:       vss[0].Namespace,
+					OwnerReferences: vss[0].OwnerReferences,
+					Labels:          vss[0].Labels,
+					Annotations:     vss[0].Annotations,
+				},
+				Spec: vss[0].Spec,
+			}
+
+			current, err = c.istioNetworking.VirtualServices(nvs.Namespace).Create(ctx, nvs, metav1.CreateOptions{FieldManager: c.identity.managedBy})
+			if errors.IsAlreadyExists(err) {
+				// Created since the cache was synced, such as by a previous migration which failed midway
+				current, err = c.istioNetworking.VirtualServices(nvs.Namespace).Get(ctx, nvs.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				if !metav1.IsControlledBy(current, ingress) {
+					klog.InfoS("virtualservice of the new name is not owned by the ingress, skipping", "virtualService", klog.KObj(current), "ingress", klog.KObj(ingress))
+					continue
+				}
+			} else if err != nil {
+				return err
+			} else {
+				virtualServiceOperationsTotal.WithLabelValues("create").Inc()
+			}
+		}
+
+		if err := c.renameRootVirtualServiceDelegate(ingress, current); err != nil {
+			return err
+		}
+
+		for _, vs := range vss {
+			if vs.Name == current.Name {
+				continue
+			}
+
+			klog.InfoS("removing migrated virtualservice", "virtualService", klog.KObj(vs))
+			err = c.istioNetworking.VirtualServices(vs.Namespace).Delete(ctx, vs.Name, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			virtualServiceOperationsTotal.WithLabelValues("delete").Inc()
+		}
+	}
+
+	return nil
+}
+
+// sortVirtualServicesByAge sorts the VirtualServices oldest first, by name when created at the same time.
+func sortVirtualServicesByAge(vss []*istionetworkingv1beta1.VirtualService) {
+	sort.Slice(vss, func(i, j int) bool {
+		if vss[i].CreationTimestamp.Equal(&vss[j].CreationTimestamp) {
+			return vss[i].Name < vss[j].Name
+		}
+		return vss[i].CreationTimestamp.Before(&vss[j].CreationTimestamp)
+	})
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestTruncateName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{
+			name: "short name",
+			in:   "web-vs",
+			max:  validation.DNS1123SubdomainMaxLength,
+			want: "web-vs",
+		},
+		{
+			name: "name of the maximum length",
+			in:   long[:63],
+			max:  validation.LabelValueMaxLength,
+			want: long[:63],
+		},
+		{
+			name: "long name",
+			in:   long,
+			max:  validation.LabelValueMaxLength,
+			want: long[:54] + "-" + "5904740b",
+		},
+		{
+			name: "separators at the end of the truncated name",
+			in:   strings.Repeat("a", 50) + ".---" + strings.Repeat("b", 20),
+			max:  validation.LabelValueMaxLength,
+			want: strings.Repeat("a", 50) + "-" + "2fd1f938",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := truncateName(test.in, test.max)
+			if len(got) > test.max {
+				t.Errorf("expected at most %d characters, got %d", test.max, len(got))
+			}
+			if errs := validation.IsValidLabelValue(got); len(errs) > 0 {
+				t.Errorf("invalid label value %q: %v", got, errs)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestGeneratedName(t *testing.T) {
+	long := strings.Repeat("a", 253)
+
+	if got := generatedName("app", "web", "istio-system", "ingressgateway"); got != "app-web-istio-system-ingressgateway" {
+		t.Errorf("unexpected name %q", got)
+	}
+
+	// Names differing past the maximum length remain unique
+	a, b := generatedName("app", long, "a"), generatedName("app", long, "b")
+	if a == b {
+		t.Errorf("expected unique names, got %q", a)
+	}
+	for _, name := range []string{a, b} {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			t.Errorf("invalid name %q: %v", name, errs)
+		}
+	}
+}
+
+func TestMigrateVirtualServiceNames(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Number: 80}
+	ingress := testIngress("web", "a.example.com", "/", "web", http80, nil)
+	ingress.UID = "web-uid"
+	recreated := ingress.DeepCopy()
+	recreated.UID = "recreated-uid"
+
+	owned := func(name string) *istionetworkingv1beta1.VirtualService {
+		return &istionetworkingv1beta1.VirtualService{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "app",
+				Labels:          map[string]string{managedByLabel: DefaultManagedBy},
+				OwnerReferences: []metav1.OwnerReference{ingressOwnerReference(ingress)},
+			},
+		}
+	}
+	root := &istionetworkingv1beta1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: "root", Namespace: "istio-system"},
+		Spec: v1beta1.VirtualService{
+			Http: []*v1beta1.HTTPRoute{{
+				Name:     DefaultIdentity().delegateRouteName("app", "web"),
+				Delegate: &v1beta1.Delegate{Name: "web-x7k2p", Namespace: "app"},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		ingress  *networkingv1.Ingress
+		cached   []*istionetworkingv1beta1.VirtualService
+		existing []*istionetworkingv1beta1.VirtualService
+		want     []string
+		delegate string
+	}{
+		{
+			name:     "renamed",
+			ingress:  ingress,
+			cached:   []*istionetworkingv1beta1.VirtualService{owned("web-x7k2p")},
+			want:     []string{virtualServiceName("web")},
+			delegate: virtualServiceName("web"),
+		},
+		{
+			name:     "new name created since the cache was synced",
+			ingress:  ingress,
+			cached:   []*istionetworkingv1beta1.VirtualService{owned("web-x7k2p")},
+			existing: []*istionetworkingv1beta1.VirtualService{owned(virtualServiceName("web"))},
+			want:     []string{virtualServiceName("web")},
+			delegate: virtualServiceName("web"),
+		},
+		{
+			name:     "deleted ingress",
+			cached:   []*istionetworkingv1beta1.VirtualService{owned("web-x7k2p")},
+			want:     []string{"web-x7k2p"},
+			delegate: "web-x7k2p",
+		},
+		{
+			name:     "recreated ingress",
+			ingress:  recreated,
+			cached:   []*istionetworkingv1beta1.VirtualService{owned("web-x7k2p")},
+			want:     []string{"web-x7k2p"},
+			delegate: "web-x7k2p",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{root.DeepCopy()}
+			for _, vs := range test.cached {
+				objects = append(objects, vs.DeepCopy())
+			}
+			if test.ingress != nil {
+				objects = append(objects, test.ingress)
+			}
+
+			c := newTestController(t, Config{}, objects...)
+			c.rootVirtualService = "istio-system/root"
+
+			clientObjects := []runtime.Object{root.DeepCopy()}
+			for _, vs := range append(test.cached, test.existing...) {
+				clientObjects = append(clientObjects, vs.DeepCopy())
+			}
+			client := istiofake.NewSimpleClientset(clientObjects...)
+			c.istioNetworking = &istioNetworking{version: IstioNetworkingV1beta1, istioclientset: client}
+
+			if err := c.migrateVirtualServiceNames(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			list, err := client.NetworkingV1beta1().VirtualServices("app").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := []string{}
+			for _, vs := range list.Items {
+				names = append(names, vs.Name)
+			}
+			if diff := cmp.Diff(test.want, names); diff != "" {
+				t.Errorf("unexpected virtualservices (-want +got):\n%s", diff)
+			}
+
+			updated, err := client.NetworkingV1beta1().VirtualServices("istio-system").Get(context.Background(), "root", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := updated.Spec.Http[0].Delegate.Name; got != test.delegate {
+				t.Errorf("expected the root to delegate to %q, got %q", test.delegate, got)
+			}
+		})
+	}
+}
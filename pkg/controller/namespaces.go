@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"time"
+
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istionetworkinginformers "istio.io/client-go/pkg/informers/externalversions/networking/v1beta1"
+	istionetworkinglisters "istio.io/client-go/pkg/listers/networking/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	networkinginformers "k8s.io/client-go/informers/networking/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// When the controller is limited to a list of namespaces, the Ingresses, Services and VirtualServices
+// are watched by one informer per namespace, which are combined into a single informer
+// so that the controller does not need to know how they are watched.
+
+// multiNamespaceInformer combines the informers of a resource in several namespaces.
+type multiNamespaceInformer[L any] struct {
+	informer cache.SharedIndexInformer
+	lister   L
+}
+
+func (i *multiNamespaceInformer[L]) Informer() cache.SharedIndexInformer {
+	return i.informer
+}
+
+func (i *multiNamespaceInformer[L]) Lister() L {
+	return i.lister
+}
+
+// multiNamespaceSharedInformer combines the shared informers of a resource in several namespaces.
+// Event handlers are added to all the informers and the informer has synced once all of them have.
+// The indexers are added to all the informers and looked up in all of them by the indexer of the informer.
+// The other methods are those of the informer of the first namespace.
+type multiNamespaceSharedInformer struct {
+	cache.SharedIndexInformer
+	informers []cache.SharedIndexInformer
+}
+
+func newMultiNamespaceSharedInformer(informers []cache.SharedIndexInformer) *multiNamespaceSharedInformer {
+	return &multiNamespaceSharedInformer{
+		SharedIndexInformer: informers[0],
+		informers:           informers,
+	}
+}
+
+func (i *multiNamespaceSharedInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	for _, informer := range i.informers {
+		informer.AddEventHandler(handler)
+	}
+}
+
+func (i *multiNamespaceSharedInformer) AddEventHandlerWithResyncPeriod(handler cache.ResourceEventHandler, resyncPeriod time.Duration) {
+	for _, informer := range i.informers {
+		informer.AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	}
+}
+
+func (i *multiNamespaceSharedInformer) AddIndexers(indexers cache.Indexers) error {
+	for _, informer := range i.informers {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *multiNamespaceSharedInformer) GetIndexer() cache.Indexer {
+	indexers := []cache.Indexer{}
+	for _, informer := range i.informers {
+		indexers = append(indexers, informer.GetIndexer())
+	}
+
+	return &multiNamespaceIndexer{Indexer: indexers[0], indexers: indexers}
+}
+
+func (i *multiNamespaceSharedInformer) HasSynced() bool {
+	for _, informer := range i.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (i *multiNamespaceSharedInformer) Run(stopCh <-chan struct{}) {
+	for _, informer := range i.informers {
+		go informer.Run(stopCh)
+	}
+	<-stopCh
+}
+
+// multiNamespaceIndexer looks up the objects by index in the indexers of several namespaces.
+// The other methods are those of the indexer of the first namespace.
+type multiNamespaceIndexer struct {
+	cache.Indexer
+	indexers []cache.Indexer
+}
+
+func (i *multiNamespaceIndexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	ret := []interface{}{}
+	for _, indexer := range i.indexers {
+		items, err := indexer.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, items...)
+	}
+
+	return ret, nil
+}
+
+// listNamespaces lists the objects of all the namespaces.
+func listNamespaces[L any, T any](listers map[string]L, list func(L) ([]T, error)) ([]T, error) {
+	ret := []T{}
+	for _, lister := range listers {
+		items, err := list(lister)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, items...)
+	}
+
+	return ret, nil
+}
+
+// NewMultiNamespaceIngressInformer combines the Ingress informers of several namespaces, by namespace.
+func NewMultiNamespaceIngressInformer(informers map[string]networkinginformers.IngressInformer) networkinginformers.IngressInformer {
+	shared := []cache.SharedIndexInformer{}
+	listers := map[string]networkinglisters.IngressLister{}
+	for namespace, informer := range informers {
+		shared = append(shared, informer.Informer())
+		listers[namespace] = informer.Lister()
+	}
+
+	return &multiNamespaceInformer[networkinglisters.IngressLister]{
+		informer: newMultiNamespaceSharedInformer(shared),
+		lister:   &multiNamespaceIngressLister{listers: listers},
+	}
+}
+
+type multiNamespaceIngressLister struct {
+	listers map[string]networkinglisters.IngressLister
+}
+
+func (l *multiNamespaceIngressLister) List(selector labels.Selector) ([]*networkingv1.Ingress, error) {
+	return listNamespaces(l.listers, func(lister networkinglisters.IngressLister) ([]*networkingv1.Ingress, error) {
+		return lister.List(selector)
+	})
+}
+
+func (l *multiNamespaceIngressLister) Ingresses(namespace string) networkinglisters.IngressNamespaceLister {
+	if lister, ok := l.listers[namespace]; ok {
+		return lister.Ingresses(namespace)
+	}
+
+	// Namespaces which are not watched have no Ingresses
+	return networkinglisters.NewIngressLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})).Ingresses(namespace)
+}
+
+// NewMultiNamespaceServiceInformer combines the Service informers of several namespaces, by namespace.
+func NewMultiNamespaceServiceInformer(informers map[string]corev1informers.ServiceInformer) corev1informers.ServiceInformer {
+	shared := []cache.SharedIndexInformer{}
+	listers := map[string]corev1listers.ServiceLister{}
+	for namespace, informer := range informers {
+		shared = append(shared, informer.Informer())
+		listers[namespace] = informer.Lister()
+	}
+
+	return &multiNamespaceInformer[corev1listers.ServiceLister]{
+		informer: newMultiNamespaceSharedInformer(shared),
+		lister:   &multiNamespaceServiceLister{listers: listers},
+	}
+}
+
+type multiNamespaceServiceLister struct {
+	listers map[string]corev1listers.ServiceLister
+}
+
+func (l *multiNamespaceServiceLister) List(selector labels.Selector) ([]*corev1.Service, error) {
+	return listNamespaces(l.listers, func(lister corev1listers.ServiceLister) ([]*corev1.Service, error) {
+		return lister.List(selector)
+	})
+}
+
+func (l *multiNamespaceServiceLister) Services(namespace string) corev1listers.ServiceNamespaceLister {
+	if lister, ok := l.listers[namespace]; ok {
+		return lister.Services(namespace)
+	}
+
+	// Namespaces which are not watched have no Services
+	return corev1listers.NewServiceLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})).Services(namespace)
+}
+
+// NewMultiNamespaceVirtualServiceInformer combines the VirtualService informers of several namespaces, by namespace.
+func NewMultiNamespaceVirtualServiceInformer(informers map[string]istionetworkinginformers.VirtualServiceInformer) istionetworkinginformers.VirtualServiceInformer {
+	shared := []cache.SharedIndexInformer{}
+	listers := map[string]istionetworkinglisters.VirtualServiceLister{}
+	for namespace, informer := range informers {
+		shared = append(shared, informer.Informer())
+		listers[namespace] = informer.Lister()
+	}
+
+	return &multiNamespaceInformer[istionetworkinglisters.VirtualServiceLister]{
+		informer: newMultiNamespaceSharedInformer(shared),
+		lister:   &multiNamespaceVirtualServiceLister{listers: listers},
+	}
+}
+
+type multiNamespaceVirtualServiceLister struct {
+	listers map[string]istionetworkinglisters.VirtualServiceLister
+}
+
+func (l *multiNamespaceVirtualServiceLister) List(selector labels.Selector) ([]*istionetworkingv1beta1.VirtualService, error) {
+	return listNamespaces(l.listers, func(lister istionetworkinglisters.VirtualServiceLister) ([]*istionetworkingv1beta1.VirtualService, error) {
+		return lister.List(selector)
+	})
+}
+
+func (l *multiNamespaceVirtualServiceLister) VirtualServices(namespace string) istionetworkinglisters.VirtualServiceNamespaceLister {
+	if lister, ok := l.listers[namespace]; ok {
+		return lister.VirtualServices(namespace)
+	}
+
+	// Namespaces which are not watched have no VirtualServices
+	return istionetworkinglisters.NewVirtualServiceLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})).VirtualServices(namespace)
+}
+
+// namespaceMatches determines if the namespace matches the namespace selector.
+// All the namespaces match if there is no selector.
+func (c *Controller) namespaceMatches(namespace string) bool {
+	if c.namespaceSelector == nil {
+		return true
+	}
+
+	ns, err := c.namespacesLister.Get(namespace)
+	if err != nil {
+		// The namespace is unknown or being deleted, in which case its Ingresses are gone
+		return false
+	}
+
+	return c.namespaceSelector.Matches(labels.Set(ns.Labels))
+}
+
+// handleNamespace re-enqueues the Ingresses of a namespace which started or stopped
+// matching the namespace selector, so that they are translated or cleaned up.
+func (c *Controller) handleNamespace(old, new interface{}) {
+	ons := old.(*corev1.Namespace)
+	nns := new.(*corev1.Namespace)
+
+	if c.namespaceSelector.Matches(labels.Set(ons.Labels)) == c.namespaceSelector.Matches(labels.Set(nns.Labels)) {
+		return
+	}
+
+	ingresses, err := c.ingressesLister.Ingresses(nns.Name).List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "error listing the ingresses of the namespace", "namespace", nns.Name)
+		return
+	}
+
+	klog.InfoS("namespace selector match changed, re-enqueuing ingresses", "namespace", nns.Name, "matches", c.namespaceSelector.Matches(labels.Set(nns.Labels)), "ingresses", len(ingresses))
+	for _, ingress := range ingresses {
+		key, err := cache.MetaNamespaceKeyFunc(ingress)
+		if err != nil {
+			klog.ErrorS(err, "error computing the key of the ingress", "ingress", klog.KObj(ingress))
+			continue
+		}
+		c.workqueue.Add(key)
+	}
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	networkingv1 "k8s.io/api/networking/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	networkinginformers "k8s.io/client-go/informers/networking/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestMultiNamespaceIngressIndexer(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+
+	client := kubefake.NewSimpleClientset()
+	informers := map[string]networkinginformers.IngressInformer{}
+	for _, namespace := range []string{"app", "other"} {
+		informers[namespace] = kubeinformers.NewSharedInformerFactoryWithOptions(client, 0, kubeinformers.WithNamespace(namespace)).Networking().V1().Ingresses()
+	}
+
+	informer := NewMultiNamespaceIngressInformer(informers)
+	if err := informer.Informer().AddIndexers(cache.Indexers{ingressHostIndex: ingressHostIndexFunc}); err != nil {
+		t.Fatal(err)
+	}
+
+	for namespace, ingress := range map[string]*networkingv1.Ingress{
+		"app":   testIngress("web", "a.example.com", "/", "web", http80, nil),
+		"other": testIngress("web", "a.example.com", "/other", "web", http80, nil),
+	} {
+		ingress.Namespace = namespace
+		if err := informers[namespace].Informer().GetIndexer().Add(ingress); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objs, err := informer.Informer().GetIndexer().ByIndex(ingressHostIndex, "a.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys := []string{}
+	for _, obj := range objs {
+		keys = append(keys, cacheKey(obj.(*networkingv1.Ingress)))
+	}
+	sort.Strings(keys)
+
+	if diff := cmp.Diff([]string{"app/web", "other/web"}, keys); diff != "" {
+		t.Errorf("unexpected ingresses (-want +got):\n%s", diff)
+	}
+}
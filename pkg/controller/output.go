@@ -0,0 +1,106 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/klog/v2"
+)
+
+// Output modes of the controller.
+const (
+	// Ingresses are translated into Istio VirtualServices
+	VirtualServiceOutputMode = "virtualservice"
+	// Ingresses are translated into Gateway API HTTPRoutes
+	HTTPRouteOutputMode = "httproute"
+	// Ingresses are translated into Istio VirtualServices delegated from a root VirtualService
+	DelegateOutputMode = "delegate"
+)
+
+// output translates the Ingresses into the routing resources of an output mode.
+type output interface {
+	// sync synchronizes the routing resources for the Ingress and returns the status
+	// of the Load Balancer to report on the Ingress. If the Ingress is not handled,
+	// its routing resources are removed and a nil status is returned.
+	sync(ingress *networkingv1.Ingress) (*corev1.LoadBalancerStatus, error)
+
+	// status returns the status of the Load Balancer to report on a handled Ingress
+	// from its existing routing resources, without changing them.
+	// A nil status is returned if the routing resources do not exist yet.
+	status(ingress *networkingv1.Ingress) (*corev1.LoadBalancerStatus, error)
+}
+
+// newOutput returns the output for the given mode, defaulting to the virtualservice output.
+func newOutput(c *Controller, mode string) output {
+	switch mode {
+	case HTTPRouteOutputMode:
+		return &httpRouteOutput{controller: c}
+	case DelegateOutputMode:
+		return &delegateVirtualServiceOutput{controller: c}
+	default:
+		return &virtualServiceOutput{controller: c}
+	}
+}
+
+// virtualServiceOutput translates Ingresses into Istio VirtualServices.
+type virtualServiceOutput struct {
+	controller *Controller
+}
+
+func (o *virtualServiceOutput) sync(ingress *networkingv1.Ingress) (*corev1.LoadBalancerStatus, error) {
+	c := o.controller
+
+	// Handle the VirtualService
+	vs, err := c.handleVirtualServiceForIngress(ingress)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle virtual service", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	// Handle the external authorization for the Ingress
+	err = c.handleAuthorizationPoliciesForIngress(ingress, vs)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle authorization policies", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	// Handle the rate limit for the Ingress
+	err = c.handleRateLimitFiltersForIngress(ingress, vs)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle rate limit filters", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	// Handle the ExternalName Services referenced by the Ingress
+	err = c.handleExternalServicesForIngress(ingress, vs != nil)
+	if err != nil {
+		klog.ErrorS(err, "failed to handle external services", c.logValues(ingress.Namespace, ingress.Name)...)
+		return nil, err
+	}
+
+	if vs == nil {
+		return nil, nil
+	}
+
+	loadBalancerStatus, err := c.getLoadBalancerStatusForVirtualService(vs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loadBalancerStatus, nil
+}
+
+func (o *virtualServiceOutput) status(ingress *networkingv1.Ingress) (*corev1.LoadBalancerStatus, error) {
+	c := o.controller
+
+	vs, err := c.findExistingVirtualServiceForIngress(ingress)
+	if err != nil || vs == nil {
+		return nil, err
+	}
+
+	loadBalancerStatus, err := c.getLoadBalancerStatusForVirtualService(vs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loadBalancerStatus, nil
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// ownedObject is a pointer to a resource generated for an Ingress.
+type ownedObject[T any] interface {
+	*T
+	metav1.Object
+}
+
+// ownedKind describes how the resources of a kind generated for the Ingresses are written.
+type ownedKind[T any] struct {
+	// Name of the kind in the log messages, such as "authorization policy"
+	name string
+	// Key of the resources in the log messages, such as "authorizationPolicy"
+	logKey string
+	// Client of the resources of the namespace
+	client func(namespace string) istioNetworkingClient[T]
+	// Returns a copy of the current resource with the spec of the desired resource
+	withSpec func(current, desired *T) *T
+}
+
+// syncOwnedObjects synchronizes the existing resources of a kind generated for the Ingress with the desired ones.
+// The missing resources are created, those whose labels or spec differ are updated
+// and those which are no longer desired are removed.
+func syncOwnedObjects[T any, PT ownedObject[T]](c *Controller, namespace, name string, kind ownedKind[T], existing, desired []*T) error {
+	ctx := context.Background()
+
+	for _, obj := range desired {
+		object := PT(obj)
+		current := findOwnedObject[T, PT](existing, object.GetNamespace(), object.GetName())
+
+		if current == nil {
+			klog.InfoS("creating "+kind.name, c.logValues(namespace, name, kind.logKey, klog.KObj(object))...)
+			if _, err := kind.client(object.GetNamespace()).Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		updated := PT(kind.withSpec(current, obj))
+		updated.SetLabels(object.GetLabels())
+		if reflect.DeepEqual(current, (*T)(updated)) {
+			continue
+		}
+
+		klog.InfoS("updating "+kind.name, c.logValues(namespace, name, kind.logKey, klog.KObj(object))...)
+		if _, err := kind.client(object.GetNamespace()).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	// Remove the resources which are no longer desired
+	for _, obj := range existing {
+		object := PT(obj)
+		if findOwnedObject[T, PT](desired, object.GetNamespace(), object.GetName()) != nil {
+			continue
+		}
+
+		klog.InfoS("removing "+kind.name, c.logValues(namespace, name, kind.logKey, klog.KObj(object))...)
+		err := kind.client(object.GetNamespace()).Delete(ctx, object.GetName(), metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findOwnedObject returns the resource of the namespace and name among the resources, or nil if there is none.
+func findOwnedObject[T any, PT ownedObject[T]](objects []*T, namespace, name string) *T {
+	for _, obj := range objects {
+		if PT(obj).GetNamespace() == namespace && PT(obj).GetName() == name {
+			return obj
+		}
+	}
+
+	return nil
+}
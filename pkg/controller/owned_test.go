@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSyncOwnedObjects(t *testing.T) {
+	serviceEntry := func(name string, hosts ...string) *istionetworkingv1beta1.ServiceEntry {
+		return &istionetworkingv1beta1.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "app", Labels: map[string]string{managedByLabel: DefaultManagedBy}},
+			Spec:       v1beta1.ServiceEntry{Hosts: hosts},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		existing []*istionetworkingv1beta1.ServiceEntry
+		desired  []*istionetworkingv1beta1.ServiceEntry
+		actions  []string
+	}{
+		{
+			name:    "created",
+			desired: []*istionetworkingv1beta1.ServiceEntry{serviceEntry("a", "a.example.com")},
+			actions: []string{"create"},
+		},
+		{
+			name:     "unchanged",
+			existing: []*istionetworkingv1beta1.ServiceEntry{serviceEntry("a", "a.example.com")},
+			desired:  []*istionetworkingv1beta1.ServiceEntry{serviceEntry("a", "a.example.com")},
+			actions:  []string{},
+		},
+		{
+			name:     "spec changed",
+			existing: []*istionetworkingv1beta1.ServiceEntry{serviceEntry("a", "a.example.com")},
+			desired:  []*istionetworkingv1beta1.ServiceEntry{serviceEntry("a", "b.example.com")},
+			actions:  []string{"update"},
+		},
+		{
+			name: "labels changed",
+			existing: func() []*istionetworkingv1beta1.ServiceEntry {
+				se := serviceEntry("a", "a.example.com")
+				se.Labels = map[string]string{}
+				return []*istionetworkingv1beta1.ServiceEntry{se}
+			}(),
+			desired: []*istionetworkingv1beta1.ServiceEntry{serviceEntry("a", "a.example.com")},
+			actions: []string{"update"},
+		},
+		{
+			name:     "no longer desired",
+			existing: []*istionetworkingv1beta1.ServiceEntry{serviceEntry("a", "a.example.com"), serviceEntry("b", "b.example.com")},
+			desired:  []*istionetworkingv1beta1.ServiceEntry{serviceEntry("a", "a.example.com")},
+			actions:  []string{"delete"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{}
+			for _, se := range test.existing {
+				objects = append(objects, se.DeepCopy())
+			}
+
+			c := newTestController(t, Config{})
+			client := istiofake.NewSimpleClientset(objects...)
+			c.istioNetworking = &istioNetworking{version: IstioNetworkingV1beta1, istioclientset: client}
+
+			if err := syncOwnedObjects(c, "app", "web", c.externalServiceEntries(), test.existing, test.desired); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			actions := []string{}
+			for _, action := range client.Actions() {
+				actions = append(actions, action.GetVerb())
+			}
+			if diff := cmp.Diff(test.actions, actions); diff != "" {
+				t.Errorf("unexpected actions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
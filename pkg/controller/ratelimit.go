@@ -0,0 +1,534 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/types"
+	"istio.io/api/networking/v1alpha3"
+	istionetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// Number of requests allowed per unit
+	RateLimitRequestsAnnotation = "ingress.statcan.gc.ca/rate-limit-requests"
+	// Unit of the rate limit (second, minute or hour)
+	RateLimitUnitAnnotation = "ingress.statcan.gc.ca/rate-limit-unit"
+	// Maximum number of requests allowed in a burst (defaults to the number of requests)
+	RateLimitBurstAnnotation = "ingress.statcan.gc.ca/rate-limit-burst"
+)
+
+const (
+	localRateLimitFilterName = "envoy.filters.http.local_ratelimit"
+	localRateLimitTypeURL    = "type.googleapis.com/envoy.extensions.filters.http.local_ratelimit.v3.LocalRateLimit"
+)
+
+// rateLimit is the local rate limit requested for an Ingress.
+type rateLimit struct {
+	requests     uint32
+	burst        uint32
+	fillInterval string
+}
+
+// handleRateLimitFiltersForIngress synchronizes the EnvoyFilters applying
+// the local rate limit of the Ingress to its virtual hosts on the gateway workloads.
+// If vs is nil, the Ingress is not handled and all of its filters are removed.
+func (c *Controller) handleRateLimitFiltersForIngress(ingress *networkingv1.Ingress, vs *istionetworkingv1beta1.VirtualService) error {
+	// Left unchanged in the dry-run mode
+	if c.dryRun {
+		return nil
+	}
+
+	desired := []*istionetworkingv1alpha3.EnvoyFilter{}
+
+	var limit *rateLimit
+	var err error
+	if vs != nil && !c.disableRateLimiting {
+		limit, err = parseRateLimit(c.identity, ingress)
+		if err != nil {
+			return err
+		}
+	}
+
+	existing, err := c.envoyFiltersLister.List(c.identity.ingressReferenceSelector(ingress.Namespace, ingress.Name))
+	if err != nil {
+		return err
+	}
+
+	// The conflicts are reported once all the filters are generated, so that they are only recorded when they change
+	conflictWarnings := []string{}
+	if limit != nil {
+		gateways, err := c.getGatewaysForVirtualService(vs)
+		if err != nil {
+			return err
+		}
+
+		for _, gateway := range gateways {
+			if len(gateway.Spec.Selector) == 0 {
+				klog.InfoS("gateway has no workload selector, skipping rate limit", c.logValues(ingress.Namespace, ingress.Name, "gateway", klog.KObj(gateway))...)
+				continue
+			}
+
+			namespaces, err := c.getWorkloadNamespacesForGateway(gateway)
+			if err != nil {
+				return err
+			}
+
+			for _, namespace := range namespaces {
+				filter, err := generateRateLimitFilter(c.identity, ingress, gateway, namespace, limit)
+				if err != nil {
+					return err
+				}
+
+				conflicts, err := c.removeConflictingRateLimitPatches(filter, findOwnedObject(existing, filter.Namespace, filter.Name))
+				if err != nil {
+					return err
+				}
+				for _, conflict := range conflicts {
+					warning := fmt.Sprintf("Rate limit of host %q is already applied by Ingress %q", conflict.host, conflict.ingress)
+					if !stringInArray(warning, conflictWarnings) {
+						conflictWarnings = append(conflictWarnings, warning)
+					}
+				}
+				if len(filter.Spec.ConfigPatches) == 0 {
+					continue
+				}
+
+				if err := c.ensureRateLimitFilterForGateway(ingress, gateway, namespace); err != nil {
+					return err
+				}
+
+				desired = append(desired, filter)
+			}
+		}
+	}
+	c.recordWarnings(ingress, ReasonRateLimitConflict, conflictWarnings)
+
+	if err := syncOwnedObjects(c, ingress.Namespace, ingress.Name, c.rateLimitFilters(), existing, desired); err != nil {
+		return err
+	}
+
+	return c.removeUnusedRateLimitFilters(desired)
+}
+
+// rateLimitFilters describes the EnvoyFilters applying the rate limits of the Ingresses.
+func (c *Controller) rateLimitFilters() ownedKind[istionetworkingv1alpha3.EnvoyFilter] {
+	return ownedKind[istionetworkingv1alpha3.EnvoyFilter]{
+		name:   "rate limit filter",
+		logKey: "envoyFilter",
+		client: func(namespace string) istioNetworkingClient[istionetworkingv1alpha3.EnvoyFilter] {
+			return c.istioclientset.NetworkingV1alpha3().EnvoyFilters(namespace)
+		},
+		withSpec: func(current, desired *istionetworkingv1alpha3.EnvoyFilter) *istionetworkingv1alpha3.EnvoyFilter {
+			updated := current.DeepCopy()
+			updated.Spec = desired.Spec
+			return updated
+		},
+	}
+}
+
+// rateLimitConflict is a host whose rate limit is applied by another Ingress.
+type rateLimitConflict struct {
+	host    string
+	ingress string
+}
+
+// removeConflictingRateLimitPatches removes the patches of the filter for the virtual hosts whose rate limit
+// is already applied on the same gateway workloads by another Ingress, as only one of the MERGE patches
+// of a virtual host takes effect. The Ingress which applied the rate limit of a virtual host first keeps it;
+// current is the existing filter of the Ingress, if any. The hosts left to the other Ingresses are returned.
+func (c *Controller) removeConflictingRateLimitPatches(filter, current *istionetworkingv1alpha3.EnvoyFilter) ([]rateLimitConflict, error) {
+	others, err := c.envoyFiltersLister.EnvoyFilters(filter.Namespace).List(labels.SelectorFromSet(map[string]string{managedByLabel: c.identity.managedBy}))
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := map[string]string{}
+	for _, other := range others {
+		namespace, name, ok := c.identity.getIngressReference(other)
+		if !ok || other.Name == filter.Name || !reflect.DeepEqual(other.Spec.WorkloadSelector.GetLabels(), filter.Spec.WorkloadSelector.GetLabels()) {
+			continue
+		}
+
+		if current != nil && (current.CreationTimestamp.Before(&other.CreationTimestamp) ||
+			(current.CreationTimestamp.Equal(&other.CreationTimestamp) && current.Name < other.Name)) {
+			continue
+		}
+
+		for _, patch := range other.Spec.ConfigPatches {
+			if vhost := patch.GetMatch().GetRouteConfiguration().GetVhost().GetName(); vhost != "" {
+				claimed[vhost] = fmt.Sprintf("%s/%s", namespace, name)
+			}
+		}
+	}
+
+	conflicts := []rateLimitConflict{}
+	patches := []*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{}
+	for _, patch := range filter.Spec.ConfigPatches {
+		vhost := patch.GetMatch().GetRouteConfiguration().GetVhost().GetName()
+		ingress, ok := claimed[vhost]
+		if !ok {
+			patches = append(patches, patch)
+			continue
+		}
+
+		conflict := rateLimitConflict{host: vhost[:strings.LastIndex(vhost, ":")], ingress: ingress}
+		if !rateLimitConflictInArray(conflict, conflicts) {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	filter.Spec.ConfigPatches = patches
+
+	return conflicts, nil
+}
+
+func rateLimitConflictInArray(conflict rateLimitConflict, conflicts []rateLimitConflict) bool {
+	for _, c := range conflicts {
+		if c == conflict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeUnusedRateLimitFilters removes the shared EnvoyFilters inserting the local rate limit filter
+// on gateway workloads for which no Ingress applies a rate limit anymore, other than the desired filters.
+// The filters of the Ingresses just created by other workers may not be observed yet, so they are
+// listed from the API server before a shared filter is removed; the Ingresses with a rate limit
+// are also enqueued on its removal, to recreate it if needed.
+func (c *Controller) removeUnusedRateLimitFilters(desired []*istionetworkingv1alpha3.EnvoyFilter) error {
+	ctx := context.Background()
+
+	filters, err := c.envoyFiltersLister.List(labels.SelectorFromSet(map[string]string{managedByLabel: c.identity.managedBy}))
+	if err != nil {
+		return err
+	}
+
+	for _, shared := range filters {
+		if !c.identity.isSharedRateLimitFilter(shared) || c.identity.rateLimitFilterInUse(shared, filters) || c.identity.rateLimitFilterInUse(shared, desired) {
+			continue
+		}
+
+		list, err := c.istioclientset.NetworkingV1alpha3().EnvoyFilters(shared.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s,%s", managedByLabel, c.identity.managedBy, c.identity.key(IngressNameLabel)),
+		})
+		if err != nil {
+			return err
+		}
+
+		current := make([]*istionetworkingv1alpha3.EnvoyFilter, len(list.Items))
+		for i := range list.Items {
+			current[i] = &list.Items[i]
+		}
+		if c.identity.rateLimitFilterInUse(shared, current) {
+			continue
+		}
+
+		klog.InfoS("removing unused rate limit filter", "envoyFilter", klog.KObj(shared))
+		err = c.istioclientset.NetworkingV1alpha3().EnvoyFilters(shared.Namespace).Delete(ctx, shared.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rateLimitFilterName returns the name of the EnvoyFilter inserting the local rate limit filter
+// on the gateway workloads of the selector.
+func (id *Identity) rateLimitFilterName(selector map[string]string) string {
+	return fmt.Sprintf("%s-local-ratelimit-%s", id.managedBy, hashSelector(selector))
+}
+
+// isSharedRateLimitFilter determines if the EnvoyFilter is the filter inserting the local rate limit filter,
+// which is shared by the Ingresses on the same gateway workloads.
+func (id *Identity) isSharedRateLimitFilter(filter *istionetworkingv1alpha3.EnvoyFilter) bool {
+	_, referenced := filter.Labels[id.key(IngressNameLabel)]
+	return id.isManagedByController(filter.Labels) && !referenced && filter.Name == id.rateLimitFilterName(filter.Spec.WorkloadSelector.GetLabels())
+}
+
+// rateLimitFilterInUse determines if the rate limit of an Ingress among the filters
+// is applied on the gateway workloads of the shared filter.
+func (id *Identity) rateLimitFilterInUse(shared *istionetworkingv1alpha3.EnvoyFilter, filters []*istionetworkingv1alpha3.EnvoyFilter) bool {
+	for _, filter := range filters {
+		if _, referenced := filter.Labels[id.key(IngressNameLabel)]; referenced && filter.Namespace == shared.Namespace &&
+			id.rateLimitFilterName(filter.Spec.WorkloadSelector.GetLabels()) == shared.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeRateLimitFiltersForIngress removes the EnvoyFilters
+// generated for a deleted Ingress.
+func (c *Controller) removeRateLimitFiltersForIngress(namespace, name string) error {
+	filters, err := c.envoyFiltersLister.List(c.identity.ingressReferenceSelector(namespace, name))
+	if err != nil {
+		return err
+	}
+
+	if err := syncOwnedObjects(c, namespace, name, c.rateLimitFilters(), filters, nil); err != nil {
+		return err
+	}
+
+	return c.removeUnusedRateLimitFilters(nil)
+}
+
+// ensureRateLimitFilterForGateway ensures the local rate limit HTTP filter is inserted
+// on the gateway workloads. The filter is shared by all Ingresses attached to workloads
+// with the same selector, so that requests are not counted more than once, and is removed
+// by removeUnusedRateLimitFilters once none of them has a rate limit.
+func (c *Controller) ensureRateLimitFilterForGateway(ingress *networkingv1.Ingress, gateway *istionetworkingv1beta1.Gateway, namespace string) error {
+	name := c.identity.rateLimitFilterName(gateway.Spec.Selector)
+
+	_, err := c.envoyFiltersLister.EnvoyFilters(namespace).Get(name)
+	if err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	value, err := toStruct(map[string]interface{}{
+		"name": localRateLimitFilterName,
+		"typed_config": map[string]interface{}{
+			"@type":    "type.googleapis.com/udpa.type.v1.TypedStruct",
+			"type_url": localRateLimitTypeURL,
+			"value": map[string]interface{}{
+				"stat_prefix": "http_local_rate_limiter",
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	filter := &istionetworkingv1alpha3.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				managedByLabel: c.identity.managedBy,
+			},
+		},
+		Spec: v1alpha3.EnvoyFilter{
+			WorkloadSelector: &v1alpha3.WorkloadSelector{
+				Labels: gateway.Spec.Selector,
+			},
+			ConfigPatches: []*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: v1alpha3.EnvoyFilter_HTTP_FILTER,
+					Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: v1alpha3.EnvoyFilter_GATEWAY,
+						ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &v1alpha3.EnvoyFilter_ListenerMatch{
+								FilterChain: &v1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &v1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name: "envoy.filters.network.http_connection_manager",
+										SubFilter: &v1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{
+											Name: "envoy.filters.http.router",
+										},
+									},
+								},
+							},
+						},
+					},
+					Patch: &v1alpha3.EnvoyFilter_Patch{
+						Operation: v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value:     value,
+					},
+				},
+			},
+		},
+	}
+
+	klog.InfoS("creating rate limit filter", c.logValues(ingress.Namespace, ingress.Name, "envoyFilter", klog.KObj(filter))...)
+	_, err = c.istioclientset.NetworkingV1alpha3().EnvoyFilters(namespace).Create(context.Background(), filter, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+
+	return err
+}
+
+// generateRateLimitFilter generates the EnvoyFilter configuring the token bucket
+// of the local rate limit on each virtual host of the Ingress on the gateway.
+func generateRateLimitFilter(id *Identity, ingress *networkingv1.Ingress, gateway *istionetworkingv1beta1.Gateway, namespace string, limit *rateLimit) (*istionetworkingv1alpha3.EnvoyFilter, error) {
+	value, err := toStruct(map[string]interface{}{
+		"typed_per_filter_config": map[string]interface{}{
+			localRateLimitFilterName: map[string]interface{}{
+				"@type":    "type.googleapis.com/udpa.type.v1.TypedStruct",
+				"type_url": localRateLimitTypeURL,
+				"value": map[string]interface{}{
+					"stat_prefix": "http_local_rate_limiter",
+					"token_bucket": map[string]interface{}{
+						"max_tokens":      limit.burst,
+						"tokens_per_fill": limit.requests,
+						"fill_interval":   limit.fillInterval,
+					},
+					"filter_enabled": map[string]interface{}{
+						"runtime_key": "local_rate_limit_enabled",
+						"default_value": map[string]interface{}{
+							"numerator":   100,
+							"denominator": "HUNDRED",
+						},
+					},
+					"filter_enforced": map[string]interface{}{
+						"runtime_key": "local_rate_limit_enforced",
+						"default_value": map[string]interface{}{
+							"numerator":   100,
+							"denominator": "HUNDRED",
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Virtual hosts on a gateway are named <host>:<port>
+	var ports []uint32
+	for _, server := range gateway.Spec.Servers {
+		if server.Port != nil && !uint32InArray(server.Port.Number, ports) {
+			ports = append(ports, server.Port.Number)
+		}
+	}
+
+	filter := &istionetworkingv1alpha3.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        generatedName(ingress.Namespace, ingress.Name, gateway.Namespace, gateway.Name, "ratelimit"),
+			Namespace:   namespace,
+			Labels:      id.ingressReferenceLabels(ingress.Namespace, ingress.Name),
+			Annotations: id.ingressReferenceAnnotations(ingress.Name),
+		},
+		Spec: v1alpha3.EnvoyFilter{
+			WorkloadSelector: &v1alpha3.WorkloadSelector{
+				Labels: gateway.Spec.Selector,
+			},
+		},
+	}
+
+	hosts := []string{}
+	for _, rule := range ingress.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+		if stringInArray(host, hosts) {
+			continue
+		}
+		hosts = append(hosts, host)
+
+		for _, port := range ports {
+			filter.Spec.ConfigPatches = append(filter.Spec.ConfigPatches, &v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+				ApplyTo: v1alpha3.EnvoyFilter_VIRTUAL_HOST,
+				Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+					Context: v1alpha3.EnvoyFilter_GATEWAY,
+					ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_RouteConfiguration{
+						RouteConfiguration: &v1alpha3.EnvoyFilter_RouteConfigurationMatch{
+							Vhost: &v1alpha3.EnvoyFilter_RouteConfigurationMatch_VirtualHostMatch{
+								Name: fmt.Sprintf("%s:%d", host, port),
+							},
+						},
+					},
+				},
+				Patch: &v1alpha3.EnvoyFilter_Patch{
+					Operation: v1alpha3.EnvoyFilter_Patch_MERGE,
+					Value:     value,
+				},
+			})
+		}
+	}
+
+	return filter, nil
+}
+
+// parseRateLimit parses the rate limit annotations of the Ingress.
+// Returns nil if the Ingress has no rate limit.
+func parseRateLimit(id *Identity, ingress *networkingv1.Ingress) (*rateLimit, error) {
+	val, ok := ingress.Annotations[id.key(RateLimitRequestsAnnotation)]
+	if !ok {
+		return nil, nil
+	}
+
+	requests, err := strconv.ParseUint(val, 10, 32)
+	if err != nil || requests == 0 {
+		return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on \"%s/%s\": %q", id.key(RateLimitRequestsAnnotation), ingress.Namespace, ingress.Name, val)
+	}
+
+	limit := &rateLimit{
+		requests:     uint32(requests),
+		burst:        uint32(requests),
+		fillInterval: "60s",
+	}
+
+	if val, ok := ingress.Annotations[id.key(RateLimitUnitAnnotation)]; ok {
+		switch strings.ToLower(val) {
+		case "second":
+			limit.fillInterval = "1s"
+		case "minute":
+			limit.fillInterval = "60s"
+		case "hour":
+			limit.fillInterval = "3600s"
+		default:
+			return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on \"%s/%s\": %q", id.key(RateLimitUnitAnnotation), ingress.Namespace, ingress.Name, val)
+		}
+	}
+
+	if val, ok := ingress.Annotations[id.key(RateLimitBurstAnnotation)]; ok {
+		burst, err := strconv.ParseUint(val, 10, 32)
+		if err != nil || burst < requests {
+			return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on \"%s/%s\": %q (must be at least %d)", id.key(RateLimitBurstAnnotation), ingress.Namespace, ingress.Name, val, requests)
+		}
+		limit.burst = uint32(burst)
+	}
+
+	return limit, nil
+}
+
+// Converts the value into the Struct expected in EnvoyFilter patches.
+func toStruct(value map[string]interface{}) (*types.Struct, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &types.Struct{}
+	if err := jsonpb.UnmarshalString(string(data), s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Returns a short stable hash of a label selector.
+func hashSelector(selector map[string]string) string {
+	keys := make([]string, 0, len(selector))
+	for k := range selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New32a()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s,", k, selector[k])
+	}
+
+	return fmt.Sprintf("%08x", h.Sum32())
+}
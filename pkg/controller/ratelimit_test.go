@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"istio.io/api/networking/v1alpha3"
+	istionetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *rateLimit
+		err         string
+	}{
+		{
+			name: "no rate limit",
+		},
+		{
+			name:        "requests per minute by default",
+			annotations: map[string]string{RateLimitRequestsAnnotation: "100"},
+			want:        &rateLimit{requests: 100, burst: 100, fillInterval: "60s"},
+		},
+		{
+			name:        "requests per second with a burst",
+			annotations: map[string]string{RateLimitRequestsAnnotation: "10", RateLimitUnitAnnotation: "Second", RateLimitBurstAnnotation: "20"},
+			want:        &rateLimit{requests: 10, burst: 20, fillInterval: "1s"},
+		},
+		{
+			name:        "requests per hour",
+			annotations: map[string]string{RateLimitRequestsAnnotation: "1000", RateLimitUnitAnnotation: "hour"},
+			want:        &rateLimit{requests: 1000, burst: 1000, fillInterval: "3600s"},
+		},
+		{
+			name:        "no requests",
+			annotations: map[string]string{RateLimitRequestsAnnotation: "0"},
+			err:         "invalid value for " + RateLimitRequestsAnnotation,
+		},
+		{
+			name:        "invalid requests",
+			annotations: map[string]string{RateLimitRequestsAnnotation: "many"},
+			err:         "invalid value for " + RateLimitRequestsAnnotation,
+		},
+		{
+			name:        "invalid unit",
+			annotations: map[string]string{RateLimitRequestsAnnotation: "100", RateLimitUnitAnnotation: "day"},
+			err:         "invalid value for " + RateLimitUnitAnnotation,
+		},
+		{
+			name:        "burst lower than the requests",
+			annotations: map[string]string{RateLimitRequestsAnnotation: "100", RateLimitBurstAnnotation: "50"},
+			err:         "must be at least 100",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ingress := testIngress("web", "a.example.com", "/", "web", networkingv1.ServiceBackendPort{Number: 80}, test.annotations)
+
+			limit, err := parseRateLimit(DefaultIdentity(), ingress)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(test.want, limit, cmp.AllowUnexported(rateLimit{})); diff != "" {
+				t.Errorf("unexpected rate limit (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// testRateLimitFilter returns the EnvoyFilter of the rate limit of the Ingress of the app namespace
+// on the default gateway workloads, created at the given time.
+func testRateLimitFilter(t *testing.T, ingressName string, hosts []string, created time.Time) *istionetworkingv1alpha3.EnvoyFilter {
+	t.Helper()
+
+	ingress := testIngress(ingressName, hosts[0], "/", "web", networkingv1.ServiceBackendPort{Number: 80}, nil)
+	for _, host := range hosts[1:] {
+		rule := *ingress.Spec.Rules[0].DeepCopy()
+		rule.Host = host
+		ingress.Spec.Rules = append(ingress.Spec.Rules, rule)
+	}
+
+	filter, err := generateRateLimitFilter(DefaultIdentity(), ingress, testGateway("istio-system", "ingressgateway"), "istio-system", &rateLimit{requests: 10, burst: 10, fillInterval: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter.CreationTimestamp = metav1.NewTime(created)
+
+	return filter
+}
+
+func virtualHostsOfFilter(filter *istionetworkingv1alpha3.EnvoyFilter) []string {
+	vhosts := []string{}
+	for _, patch := range filter.Spec.ConfigPatches {
+		vhosts = append(vhosts, patch.GetMatch().GetRouteConfiguration().GetVhost().GetName())
+	}
+	sort.Strings(vhosts)
+
+	return vhosts
+}
+
+func TestRemoveConflictingRateLimitPatches(t *testing.T) {
+	earlier := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		existing  []*istionetworkingv1alpha3.EnvoyFilter
+		current   bool
+		vhosts    []string
+		conflicts []rateLimitConflict
+	}{
+		{
+			name:      "no other ingress",
+			vhosts:    []string{"a.example.com:80", "b.example.com:80"},
+			conflicts: []rateLimitConflict{},
+		},
+		{
+			name:      "other hosts",
+			existing:  []*istionetworkingv1alpha3.EnvoyFilter{testRateLimitFilter(t, "other", []string{"c.example.com"}, earlier)},
+			vhosts:    []string{"a.example.com:80", "b.example.com:80"},
+			conflicts: []rateLimitConflict{},
+		},
+		{
+			name:      "host applied by another ingress",
+			existing:  []*istionetworkingv1alpha3.EnvoyFilter{testRateLimitFilter(t, "other", []string{"b.example.com"}, earlier)},
+			vhosts:    []string{"a.example.com:80"},
+			conflicts: []rateLimitConflict{{host: "b.example.com", ingress: "app/other"}},
+		},
+		{
+			name:      "host applied by the ingress first",
+			existing:  []*istionetworkingv1alpha3.EnvoyFilter{testRateLimitFilter(t, "other", []string{"b.example.com"}, later)},
+			current:   true,
+			vhosts:    []string{"a.example.com:80", "b.example.com:80"},
+			conflicts: []rateLimitConflict{},
+		},
+		{
+			name:      "new ingress",
+			existing:  []*istionetworkingv1alpha3.EnvoyFilter{testRateLimitFilter(t, "other", []string{"a.example.com", "b.example.com"}, later)},
+			vhosts:    []string{},
+			conflicts: []rateLimitConflict{{host: "a.example.com", ingress: "app/other"}, {host: "b.example.com", ingress: "app/other"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{}
+			for _, filter := range test.existing {
+				objects = append(objects, filter)
+			}
+
+			filter := testRateLimitFilter(t, "web", []string{"a.example.com", "b.example.com"}, earlier.Add(time.Minute))
+			var current *istionetworkingv1alpha3.EnvoyFilter
+			if test.current {
+				current = filter.DeepCopy()
+				objects = append(objects, current)
+			}
+
+			c := newTestController(t, Config{}, objects...)
+
+			conflicts, err := c.removeConflictingRateLimitPatches(filter, current)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(test.vhosts, virtualHostsOfFilter(filter)); diff != "" {
+				t.Errorf("unexpected virtual hosts (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.conflicts, conflicts, cmp.AllowUnexported(rateLimitConflict{})); diff != "" {
+				t.Errorf("unexpected conflicts (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRemoveUnusedRateLimitFilters(t *testing.T) {
+	selector := map[string]string{"istio": "ingressgateway"}
+	shared := &istionetworkingv1alpha3.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultIdentity().rateLimitFilterName(selector),
+			Namespace: "istio-system",
+			Labels:    map[string]string{managedByLabel: DefaultManagedBy},
+		},
+		Spec: v1alpha3.EnvoyFilter{WorkloadSelector: &v1alpha3.WorkloadSelector{Labels: selector}},
+	}
+	filter := testRateLimitFilter(t, "web", []string{"a.example.com"}, time.Now())
+
+	tests := []struct {
+		name    string
+		listed  []*istionetworkingv1alpha3.EnvoyFilter
+		created []*istionetworkingv1alpha3.EnvoyFilter
+		desired []*istionetworkingv1alpha3.EnvoyFilter
+		removed bool
+	}{
+		{
+			name:    "no rate limit",
+			removed: true,
+		},
+		{
+			name:   "rate limit of an ingress",
+			listed: []*istionetworkingv1alpha3.EnvoyFilter{filter},
+		},
+		{
+			name:    "rate limit being created",
+			desired: []*istionetworkingv1alpha3.EnvoyFilter{filter},
+		},
+		{
+			name:    "rate limit created by another worker",
+			created: []*istionetworkingv1alpha3.EnvoyFilter{filter},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			listed := []runtime.Object{shared}
+			for _, f := range test.listed {
+				listed = append(listed, f)
+			}
+			created := []runtime.Object{shared}
+			for _, f := range append(test.listed, test.created...) {
+				created = append(created, f)
+			}
+
+			c := newTestController(t, Config{}, listed...)
+			c.istioclientset = istiofake.NewSimpleClientset(created...)
+
+			if err := c.removeUnusedRateLimitFilters(test.desired); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, err := c.istioclientset.NetworkingV1alpha3().EnvoyFilters(shared.Namespace).Get(context.Background(), shared.Name, metav1.GetOptions{})
+			if removed := err != nil; removed != test.removed {
+				t.Errorf("expected removed to be %t, got %t (%v)", test.removed, removed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var (
+	// Set on generated resources which live outside of the Ingress' namespace
+	// (and therefore cannot carry an owner reference) to identify the source Ingress.
+	IngressNamespaceLabel = "ingress.statcan.gc.ca/ingress-namespace"
+	IngressNameLabel      = "ingress.statcan.gc.ca/ingress-name"
+)
+
+// ingressOwnerReference returns the controller reference to the Ingress
+// set on the generated resources living in the Ingress' namespace.
+func ingressOwnerReference(ingress *networkingv1.Ingress) metav1.OwnerReference {
+	return *metav1.NewControllerRef(ingress, networkingv1.SchemeGroupVersion.WithKind("Ingress"))
+}
+
+// ingressReferenceLabels returns the labels identifying a resource generated
+// for the Ingress, when the resource cannot be owned by the Ingress directly.
+// Names of Ingresses longer than label values are truncated; the full name
+// is kept in the annotation from ingressReferenceAnnotations.
+func (id *Identity) ingressReferenceLabels(namespace, name string) map[string]string {
+	return map[string]string{
+		managedByLabel:                id.managedBy,
+		id.key(IngressNamespaceLabel): namespace,
+		id.key(IngressNameLabel):      truncateName(name, validation.LabelValueMaxLength),
+	}
+}
+
+// ingressReferenceAnnotations returns the annotation holding the full name of the Ingress,
+// under the key of its label.
+func (id *Identity) ingressReferenceAnnotations(name string) map[string]string {
+	return map[string]string{
+		id.key(IngressNameLabel): name,
+	}
+}
+
+// ingressReferenceSelector selects the resources generated for the Ingress
+// which carry the labels from ingressReferenceLabels.
+func (id *Identity) ingressReferenceSelector(namespace, name string) labels.Selector {
+	return labels.SelectorFromSet(id.ingressReferenceLabels(namespace, name))
+}
+
+// getIngressReference returns the namespace and name of the Ingress referenced
+// by the labels and annotations of a generated resource.
+func (id *Identity) getIngressReference(object metav1.Object) (namespace, name string, ok bool) {
+	objectLabels := object.GetLabels()
+	if !id.isManagedByController(objectLabels) {
+		return "", "", false
+	}
+
+	namespace, nsok := objectLabels[id.key(IngressNamespaceLabel)]
+	name, nameok := objectLabels[id.key(IngressNameLabel)]
+
+	// Resources generated before the names were truncated only have the label
+	if fullName, ok := object.GetAnnotations()[id.key(IngressNameLabel)]; ok {
+		name = fullName
+	}
+
+	return namespace, name, nsok && nameok
+}
+
+// removeResourcesForIngress removes the resources generated for a deleted Ingress
+// which are not garbage collected through owner references.
+func (c *Controller) removeResourcesForIngress(namespace, name string) error {
+	if err := c.removeAuthorizationPoliciesForIngress(namespace, name); err != nil {
+		return err
+	}
+
+	if err := c.removeClientCertificateGatewaysForIngress(namespace, name); err != nil {
+		return err
+	}
+
+	if err := c.removeRateLimitFiltersForIngress(namespace, name); err != nil {
+		return err
+	}
+
+	return c.removeRootVirtualServiceRouteForIngress(namespace, name)
+}
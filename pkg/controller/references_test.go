@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestIngressReference(t *testing.T) {
+	long := strings.Repeat("web", 30)
+
+	tests := []struct {
+		name        string
+		ingress     string
+		annotations bool
+		want        string
+	}{
+		{
+			name:        "short name",
+			ingress:     "web",
+			annotations: true,
+			want:        "web",
+		},
+		{
+			name:        "long name",
+			ingress:     long,
+			annotations: true,
+			want:        long,
+		},
+		{
+			name:    "generated before the annotation",
+			ingress: "web",
+			want:    "web",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object := &metav1.ObjectMeta{Labels: DefaultIdentity().ingressReferenceLabels("app", test.ingress)}
+			if test.annotations {
+				object.Annotations = DefaultIdentity().ingressReferenceAnnotations(test.ingress)
+			}
+
+			for key, value := range object.Labels {
+				if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+					t.Errorf("invalid value of label %s: %v", key, errs)
+				}
+			}
+
+			namespace, name, ok := DefaultIdentity().getIngressReference(object)
+			if !ok || namespace != "app" || name != test.want {
+				t.Errorf("expected app/%s, got %s/%s (%t)", test.want, namespace, name, ok)
+			}
+
+			if !DefaultIdentity().ingressReferenceSelector("app", test.ingress).Matches(labels.Set(object.Labels)) {
+				t.Errorf("expected the selector to match the labels %v", object.Labels)
+			}
+		})
+	}
+}
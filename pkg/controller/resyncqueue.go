@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// NewRateLimiter returns the rate limiter of the workqueue, which retries failed Ingresses
+// with an exponential backoff from baseDelay to maxDelay, under an overall rate of qps with bursts of burst.
+// workqueue.DefaultControllerRateLimiter uses 5ms, 1000s, 10 and 100.
+func NewRateLimiter(baseDelay, maxDelay time.Duration, qps float64, burst int) workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
+}
+
+// enqueueIngressResync queues the reconcile of an unchanged Ingress on resync.
+func (c *Controller) enqueueIngressResync(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	if namespace, _, err := cache.SplitMetaNamespaceKey(key); err == nil && !c.namespaceMatches(namespace) {
+		return
+	}
+
+	c.resyncQueue.Add(key)
+}
+
+// runResyncWorker moves the Ingresses of the resync queue to the workqueue at a limited rate,
+// where they are reconciled, repairing the drift of their resources and refreshing their status.
+// The workqueue only holds each Ingress once, so the resyncs are interleaved with the changes
+// instead of delaying them.
+func (c *Controller) runResyncWorker(ctx context.Context) {
+	for c.processNextResyncItem(ctx) {
+	}
+}
+
+func (c *Controller) processNextResyncItem(ctx context.Context) bool {
+	// The queue only holds each Ingress once, so limiting the rate
+	// at which it is processed does not let the resyncs pile up
+	if err := c.resyncLimiter.Wait(ctx); err != nil {
+		return false
+	}
+
+	obj, shutdown := c.resyncQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.resyncQueue.Done(obj)
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	key, ok := obj.(string)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("expected string in resync queue but got %#v", obj))
+		return true
+	}
+
+	c.workqueue.Add(key)
+	return true
+}
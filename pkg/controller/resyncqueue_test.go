@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/time/rate"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestResyncQueue(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+	web := testIngress("web", "a.example.com", "/", "web", http80, nil)
+
+	c := newTestController(t, Config{DefaultGateway: "istio-system/ingressgateway"}, web)
+	c.workqueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer c.workqueue.ShutDown()
+	c.resyncQueue = workqueue.New()
+	defer c.resyncQueue.ShutDown()
+	c.resyncLimiter = rate.NewLimiter(rate.Inf, 1)
+
+	// Resyncs of an Ingress which was not reconciled yet only queue it once
+	c.enqueueIngressResync(web)
+	c.enqueueIngressResync(web)
+	if c.resyncQueue.Len() != 1 {
+		t.Fatalf("expected the ingress to be queued once for resync, got %d items", c.resyncQueue.Len())
+	}
+
+	if !c.processNextResyncItem(context.Background()) {
+		t.Fatal("expected the resync queue to keep being processed")
+	}
+
+	// The unchanged Ingress is fully reconciled by the workers, repairing the drift of its resources
+	if c.resyncQueue.Len() != 0 || c.workqueue.Len() != 1 {
+		t.Fatalf("expected the ingress to be moved to the workqueue, got %d resync and %d work items", c.resyncQueue.Len(), c.workqueue.Len())
+	}
+	if key, _ := c.workqueue.Get(); key != "app/web" {
+		t.Errorf("expected %q in the workqueue, got %v", "app/web", key)
+	}
+}
@@ -2,8 +2,11 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"reflect"
+	"sort"
 	"strings"
 
 	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
@@ -12,46 +15,230 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/klog"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// Set on Gateways to select the source of the addresses published in the status of the Ingresses:
+	// auto, loadbalancer, externalips, nodeaddresses or publish
+	StatusSourceAnnotation = "ingress.statcan.gc.ca/status-source"
+	// Set on Gateways to select the nodes whose addresses are published with a label selector,
+	// such as the nodes of the pool running the gateway workloads
+	StatusNodeSelectorAnnotation = "ingress.statcan.gc.ca/status-node-selector"
+)
+
+// Sources of the addresses published in the status of the Ingresses.
+const (
+	StatusSourceAuto          = "auto"
+	StatusSourceLoadBalancer  = "loadbalancer"
+	StatusSourceExternalIPs   = "externalips"
+	StatusSourceNodeAddresses = "nodeaddresses"
+	StatusSourcePublish       = "publish"
 )
 
 // handleIngressStatus will synchronize the status of the Load Balancer
-// of the Service the Gateway is associated with.
-func (c *Controller) handleIngressStatus(ingress *networkingv1.Ingress, vs *istionetworkingv1beta1.VirtualService) (*networkingv1.Ingress, error) {
+// generated by the output for the Ingress.
+func (c *Controller) handleIngressStatus(ingress *networkingv1.Ingress, loadBalancerStatus corev1.LoadBalancerStatus) (*networkingv1.Ingress, error) {
 	ctx := context.Background()
 
-	gateways, err := c.getGatewaysForVirtualService(vs)
-	if err != nil {
-		return ingress, err
+	if c.dryRun {
+		c.setDryRunStatusChange(ingress, loadBalancerStatus)
+		return ingress, nil
+	}
+
+	// Compare the current status to the newly generated status
+	// and if they differ, apply the change.
+	if !reflect.DeepEqual(ingress.Status.LoadBalancer, loadBalancerStatus) {
+		klog.InfoS("updating ingress status", c.logValues(ingress.Namespace, ingress.Name)...)
+
+		// A merge patch only replaces the load balancer status, without conflicting
+		// with the changes made to the Ingress since it was observed.
+		// The ingress list is always set, so that an empty status clears it.
+		patch, err := json.Marshal(map[string]interface{}{
+			"status": map[string]interface{}{
+				"loadBalancer": map[string]interface{}{
+					"ingress": loadBalancerStatus.Ingress,
+				},
+			},
+		})
+		if err != nil {
+			return ingress, err
+		}
+
+		ingress, err = c.kubeclientset.NetworkingV1().Ingresses(ingress.Namespace).Patch(ctx, ingress.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: c.identity.managedBy}, "status")
+		if err != nil {
+			statusUpdatesTotal.WithLabelValues(resultError).Inc()
+			return ingress, err
+		}
+		statusUpdatesTotal.WithLabelValues(resultSuccess).Inc()
+		c.recorder.Event(ingress, corev1.EventTypeNormal, ReasonStatusUpdated, "Updated load balancer status")
 	}
 
+	return ingress, nil
+}
+
+// getLoadBalancerStatusForVirtualService returns the status of the Load Balancer
+// of the Services the Gateways of the VirtualService are associated with.
+func (c *Controller) getLoadBalancerStatusForVirtualService(vs *istionetworkingv1beta1.VirtualService) (corev1.LoadBalancerStatus, error) {
 	loadBalancerStatus := corev1.LoadBalancerStatus{}
 
+	gateways, err := c.getGatewaysForVirtualService(vs)
+	if err != nil {
+		return loadBalancerStatus, err
+	}
+
 	for _, gateway := range gateways {
-		services, err := c.getServicesForGateway(gateway)
+		ingresses, err := c.getLoadBalancerIngressForGateway(gateway)
 		if err != nil {
-			return ingress, err
+			return loadBalancerStatus, err
 		}
 
-		for _, service := range services {
-			loadBalancerStatus.Ingress = append(loadBalancerStatus.Ingress, service.Status.LoadBalancer.Ingress...)
+		loadBalancerStatus.Ingress = append(loadBalancerStatus.Ingress, ingresses...)
+	}
+
+	return loadBalancerStatus, nil
+}
+
+// getLoadBalancerIngressForGateway returns the addresses of the Gateway from the source
+// selected by its status source annotation. The published addresses are used by default
+// when configured, otherwise the addresses of the gateway Services are used.
+func (c *Controller) getLoadBalancerIngressForGateway(gateway *istionetworkingv1beta1.Gateway) ([]corev1.LoadBalancerIngress, error) {
+	source := StatusSourceAuto
+	if len(c.publishStatusAddresses) > 0 || c.publishService != "" {
+		source = StatusSourcePublish
+	}
+
+	if val, ok := gateway.Annotations[c.identity.key(StatusSourceAnnotation)]; ok {
+		source = strings.ToLower(val)
+	}
+
+	nodeSelector := labels.Everything()
+	if val, ok := gateway.Annotations[c.identity.key(StatusNodeSelectorAnnotation)]; ok {
+		var err error
+		nodeSelector, err = labels.Parse(val)
+		if err != nil {
+			return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on gateway \"%s/%s\": %v", c.identity.key(StatusNodeSelectorAnnotation), gateway.Namespace, gateway.Name, err)
 		}
 	}
 
-	// Compare the current status to the newly generated status
-	// and if they differ, apply the change.
-	if !reflect.DeepEqual(ingress.Status.LoadBalancer, loadBalancerStatus) {
-		klog.Infof("updating ingress status for \"%s/%s\"", ingress.Namespace, ingress.Name)
-		updatedIngress := ingress.DeepCopy()
-		updatedIngress.Status.LoadBalancer = loadBalancerStatus
+	switch source {
+	case StatusSourcePublish:
+		return c.getPublishedLoadBalancerIngress(nodeSelector)
+	case StatusSourceAuto, StatusSourceLoadBalancer, StatusSourceExternalIPs, StatusSourceNodeAddresses:
+	default:
+		return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s on gateway \"%s/%s\": %q", c.identity.key(StatusSourceAnnotation), gateway.Namespace, gateway.Name, source)
+	}
 
-		ingress, err = c.kubeclientset.NetworkingV1().Ingresses(updatedIngress.Namespace).UpdateStatus(ctx, updatedIngress, metav1.UpdateOptions{})
+	services, err := c.getServicesForGateway(gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	ingresses := []corev1.LoadBalancerIngress{}
+	for _, service := range services {
+		serviceIngresses, err := c.getLoadBalancerIngressForService(service, source, nodeSelector)
 		if err != nil {
-			return ingress, err
+			return nil, err
 		}
+
+		ingresses = append(ingresses, serviceIngresses...)
 	}
 
-	return ingress, nil
+	return ingresses, nil
+}
+
+// getLoadBalancerIngressForService returns the addresses of the Service from the source.
+// The auto source falls back from the load balancer addresses to the external IPs
+// and, for NodePort Services, to the addresses of the nodes matching the selector.
+func (c *Controller) getLoadBalancerIngressForService(service *corev1.Service, source string, nodeSelector labels.Selector) ([]corev1.LoadBalancerIngress, error) {
+	if source == StatusSourceLoadBalancer || (source == StatusSourceAuto && len(service.Status.LoadBalancer.Ingress) > 0) {
+		return service.Status.LoadBalancer.Ingress, nil
+	}
+
+	if source == StatusSourceExternalIPs || (source == StatusSourceAuto && len(service.Spec.ExternalIPs) > 0) {
+		return addressesToLoadBalancerIngress(service.Spec.ExternalIPs), nil
+	}
+
+	if source == StatusSourceNodeAddresses || (source == StatusSourceAuto && service.Spec.Type == corev1.ServiceTypeNodePort) {
+		return c.getNodeLoadBalancerIngress(nodeSelector)
+	}
+
+	return nil, nil
+}
+
+// getNodeLoadBalancerIngress returns the external addresses of the nodes matching the selector,
+// or their internal addresses for nodes without an external address.
+func (c *Controller) getNodeLoadBalancerIngress(selector labels.Selector) ([]corev1.LoadBalancerIngress, error) {
+	nodes, err := c.nodesLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{}
+	for _, node := range nodes {
+		external := []string{}
+		internal := []string{}
+
+		for _, address := range node.Status.Addresses {
+			switch address.Type {
+			case corev1.NodeExternalIP:
+				external = append(external, address.Address)
+			case corev1.NodeInternalIP:
+				internal = append(internal, address.Address)
+			}
+		}
+
+		if len(external) > 0 {
+			addresses = append(addresses, external...)
+		} else {
+			addresses = append(addresses, internal...)
+		}
+	}
+
+	// Sort the addresses, as the order of the nodes is not stable
+	sort.Strings(addresses)
+
+	return addressesToLoadBalancerIngress(addresses), nil
+}
+
+// getPublishedLoadBalancerIngress returns the addresses configured through
+// the publish status address, or the addresses of the publish Service.
+func (c *Controller) getPublishedLoadBalancerIngress(nodeSelector labels.Selector) ([]corev1.LoadBalancerIngress, error) {
+	if len(c.publishStatusAddresses) > 0 {
+		return addressesToLoadBalancerIngress(c.publishStatusAddresses), nil
+	}
+
+	if c.publishService == "" {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(c.publishService, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid publish service %q: expected <namespace>/<name>", c.publishService)
+	}
+
+	service, err := c.gatewayServicesLister.Services(parts[0]).Get(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return c.getLoadBalancerIngressForService(service, StatusSourceAuto, nodeSelector)
+}
+
+// addressesToLoadBalancerIngress converts IP addresses and hostnames into Load Balancer ingresses.
+func addressesToLoadBalancerIngress(addresses []string) []corev1.LoadBalancerIngress {
+	ingresses := []corev1.LoadBalancerIngress{}
+
+	for _, address := range addresses {
+		if net.ParseIP(address) != nil {
+			ingresses = append(ingresses, corev1.LoadBalancerIngress{IP: address})
+		} else {
+			ingresses = append(ingresses, corev1.LoadBalancerIngress{Hostname: address})
+		}
+	}
+
+	return ingresses
 }
 
 // getGatewaysForVirtualService will get the gateways associated with the Virtual Service.
@@ -77,13 +264,13 @@ func (c *Controller) getGatewaysByName(gatewayNames []string, currentNamespace s
 		case 2:
 			gateway, err = c.gatewaysListers.Gateways(idParts[0]).Get(idParts[1])
 		default:
-			return nil, fmt.Errorf("unexpected number of parts in Gateway identifier %q: %d", gatewayId, len(idParts))
+			return nil, newReconcileError(ReasonInvalidAnnotation, "invalid value for %s: invalid gateway %q: expected <name> or <namespace>/<name>", c.identity.key(GatewaysAnnotation), gatewayId)
 		}
 
 		// If the Gateway is not found, then ignore the error.
 		// Otherwise, this is an unexpected error and return it.
+		// Unknown gateways are reported on the Ingresses by recordUnknownGateways.
 		if err != nil && errors.IsNotFound(err) {
-			klog.Errorf("failed to load gateway %q: %v", gatewayId, err)
 			continue
 		} else if err != nil {
 			return nil, err
@@ -100,8 +287,8 @@ func (c *Controller) getServicesForGateway(gateway *istionetworkingv1beta1.Gatew
 	selector := labels.SelectorFromSet(gateway.Spec.Selector)
 
 	if c.scopedGateways {
-		return c.servicesLister.Services(gateway.Namespace).List(selector)
+		return c.gatewayServicesLister.Services(gateway.Namespace).List(selector)
 	}
 
-	return c.servicesLister.List(selector)
+	return c.gatewayServicesLister.List(selector)
 }
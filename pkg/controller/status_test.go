@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetLoadBalancerIngressForGatewayNodes(t *testing.T) {
+	node := func(name string, labels map[string]string, addresses ...corev1.NodeAddress) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Status:     corev1.NodeStatus{Addresses: addresses},
+		}
+	}
+	gatewayService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-ingressgateway", Namespace: "istio-system", Labels: map[string]string{"istio": "ingressgateway"}},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort},
+	}
+
+	tests := []struct {
+		name         string
+		nodeSelector string
+		want         []corev1.LoadBalancerIngress
+		err          bool
+	}{
+		{
+			name: "all nodes",
+			want: []corev1.LoadBalancerIngress{{IP: "192.0.2.1"}, {IP: "192.0.2.3"}, {IP: "198.51.100.2"}},
+		},
+		{
+			name:         "nodes of the gateway pool",
+			nodeSelector: "pool=gateway",
+			want:         []corev1.LoadBalancerIngress{{IP: "192.0.2.1"}, {IP: "198.51.100.2"}},
+		},
+		{
+			name:         "no matching nodes",
+			nodeSelector: "pool=none",
+			want:         []corev1.LoadBalancerIngress{},
+		},
+		{
+			name:         "invalid selector",
+			nodeSelector: "pool==gateway,",
+			err:          true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestController(t, Config{}, gatewayService,
+				node("a", map[string]string{"pool": "gateway"}, corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "192.0.2.1"}, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}),
+				node("b", map[string]string{"pool": "gateway"}, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "198.51.100.2"}),
+				node("c", nil, corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "192.0.2.3"}),
+			)
+			gateway := testGateway("istio-system", "ingressgateway")
+			if test.nodeSelector != "" {
+				gateway.Annotations = map[string]string{StatusNodeSelectorAnnotation: test.nodeSelector}
+			}
+
+			got, err := c.getLoadBalancerIngressForGateway(gateway)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("unexpected addresses (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
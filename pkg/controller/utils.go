@@ -1,5 +1,7 @@
 package controller
 
+import "strings"
+
 func stringInArray(str string, arr []string) bool {
 	for _, val := range arr {
 		if str == val {
@@ -10,6 +12,16 @@ func stringInArray(str string, arr []string) bool {
 	return false
 }
 
+func uint32InArray(val uint32, arr []uint32) bool {
+	for _, v := range arr {
+		if val == v {
+			return true
+		}
+	}
+
+	return false
+}
+
 func stringArrayEquals(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -23,3 +35,28 @@ func stringArrayEquals(a, b []string) bool {
 
 	return true
 }
+
+// Splits a comma seperated list, ignoring empty and duplicate values.
+func splitList(val string) []string {
+	list := []string{}
+
+	for _, v := range strings.Split(val, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" && !stringInArray(v, list) {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+// Determines if all of the entries of b are set in a.
+func mapContains(a, b map[string]string) bool {
+	for k, v := range b {
+		if val, ok := a[k]; !ok || val != v {
+			return false
+		}
+	}
+
+	return true
+}
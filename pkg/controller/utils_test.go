@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name string
+		val  string
+		want []string
+	}{
+		{
+			name: "empty",
+			val:  "",
+			want: []string{},
+		},
+		{
+			name: "single namespace",
+			val:  ".",
+			want: []string{"."},
+		},
+		{
+			name: "spaces around the entries",
+			val:  " ., istio-system ",
+			want: []string{".", "istio-system"},
+		},
+		{
+			name: "empty entries",
+			val:  ".,,istio-system,",
+			want: []string{".", "istio-system"},
+		},
+		{
+			name: "duplicate entries",
+			val:  "istio-system,.,istio-system",
+			want: []string{"istio-system", "."},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, splitList(test.val)); diff != "" {
+				t.Errorf("unexpected list (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Results of the admission reviews of the validating webhook.
+const (
+	admissionAllowed = "allowed"
+	admissionDenied  = "denied"
+)
+
+// ValidatingWebhookHandler returns the handler of the validating admission webhook for Ingresses.
+// The Ingresses handled by the controller are translated against the informer caches:
+// those the controller would fail to translate, whose paths are already routed by another Ingress
+// or which request gateways outside of allowedGateways are rejected, and those referencing
+// gateways or backend services which do not exist yet are admitted with warnings.
+// The allowed gateways are in the <namespace>/<name> format, where the name may be *,
+// and all gateways are allowed if there are none.
+func (c *Controller) ValidatingWebhookHandler(allowedGateways []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "expected a POST of an AdmissionReview", http.StatusMethodNotAllowed)
+			return
+		}
+
+		review := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+			webhookAdmissionsTotal.WithLabelValues(resultError).Inc()
+			http.Error(w, "expected an AdmissionReview with a request", http.StatusBadRequest)
+			return
+		}
+
+		// Let the failure policy of the webhook decide until the Ingresses can be translated
+		for name, synched := range c.informersSynched() {
+			if !synched() {
+				webhookAdmissionsTotal.WithLabelValues(resultError).Inc()
+				http.Error(w, fmt.Sprintf("informer cache %s not synced", name), http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		response, err := c.reviewIngress(review.Request, allowedGateways)
+		if err != nil {
+			webhookAdmissionsTotal.WithLabelValues(resultError).Inc()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if response.Allowed {
+			webhookAdmissionsTotal.WithLabelValues(admissionAllowed).Inc()
+		} else {
+			webhookAdmissionsTotal.WithLabelValues(admissionDenied).Inc()
+		}
+
+		// The response is in the version of the request, whose fields are the same in v1 and v1beta1
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(&admissionv1.AdmissionReview{TypeMeta: review.TypeMeta, Response: response}); err != nil {
+			klog.ErrorS(err, "error writing admission response")
+		}
+	})
+}
+
+// reviewIngress validates the Ingress of the admission request.
+// Other resources and operations are allowed.
+func (c *Controller) reviewIngress(request *admissionv1.AdmissionRequest, allowedGateways []string) (*admissionv1.AdmissionResponse, error) {
+	response := &admissionv1.AdmissionResponse{UID: request.UID, Allowed: true}
+
+	if request.Kind.Group != networkingv1.GroupName || request.Kind.Kind != "Ingress" ||
+		(request.Operation != admissionv1.Create && request.Operation != admissionv1.Update) {
+		return response, nil
+	}
+
+	ingress := &networkingv1.Ingress{}
+	if err := json.Unmarshal(request.Object.Raw, ingress); err != nil {
+		return nil, fmt.Errorf("error decoding ingress: %v", err)
+	}
+	if ingress.Namespace == "" {
+		ingress.Namespace = request.Namespace
+	}
+
+	// The conflicts of the Ingress before an update are not introduced by the update
+	var old *networkingv1.Ingress
+	if request.Operation == admissionv1.Update && len(request.OldObject.Raw) > 0 {
+		old = &networkingv1.Ingress{}
+		if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+			return nil, fmt.Errorf("error decoding old ingress: %v", err)
+		}
+		if old.Namespace == "" {
+			old.Namespace = request.Namespace
+		}
+	}
+
+	c.configLock.RLock()
+	warnings, err := c.validateIngress(c.withAnnotationDefaults(ingress), old, allowedGateways)
+	c.configLock.RUnlock()
+
+	response.Warnings = warnings
+	if err != nil {
+		klog.InfoS("rejected ingress", c.logValues(ingress.Namespace, ingress.Name, "operation", request.Operation, "reason", err.Error())...)
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+	} else if len(warnings) > 0 {
+		klog.V(2).InfoS("admitted ingress with warnings", c.logValues(ingress.Namespace, ingress.Name, "operation", request.Operation, "warnings", warnings)...)
+	}
+
+	return response, nil
+}
+
+// validateIngress translates the Ingress without applying it and returns the warnings
+// about the resources it references which do not exist yet, or the error which prevents its translation.
+// old is the Ingress before an update, or nil when it is created.
+func (c *Controller) validateIngress(ingress, old *networkingv1.Ingress, allowedGateways []string) ([]string, error) {
+	warnings := []string{}
+
+	handle, _, err := c.getIngressHandling(ingress)
+	if apierrors.IsNotFound(err) && ingress.Spec.IngressClassName != nil {
+		// The IngressClass may be created after the Ingress
+		return append(warnings, fmt.Sprintf("IngressClass %q does not exist", *ingress.Spec.IngressClassName)), nil
+	} else if err != nil {
+		return warnings, err
+	}
+	if !handle {
+		return warnings, nil
+	}
+
+	if val, ok := ingress.Annotations[c.identity.key(GatewaysAnnotation)]; ok && c.rootVirtualService == "" {
+		if err := validateGatewayNames(strings.Split(val, ","), ingress.Namespace, allowedGateways); err != nil {
+			return warnings, fmt.Errorf("invalid value for %s: %v", c.identity.key(GatewaysAnnotation), err)
+		}
+	}
+
+	for _, name := range c.getMissingBackendServices(ingress) {
+		warnings = append(warnings, fmt.Sprintf("Backend service %q does not exist", name))
+	}
+
+	if err := c.validateTranslation(ingress, &warnings); err != nil {
+		return warnings, err
+	}
+
+	if old != nil {
+		if handle, _, err := c.getIngressHandling(c.withAnnotationDefaults(old)); err != nil || !handle {
+			old = nil
+		}
+	}
+
+	return warnings, c.checkPathConflicts(ingress, old)
+}
+
+// validateTranslation generates the routing resources of the Ingress in the output mode of the controller.
+func (c *Controller) validateTranslation(ingress *networkingv1.Ingress, warnings *[]string) error {
+	if _, ok := c.output.(*httpRouteOutput); ok {
+		_, err := c.generateHTTPRoutes(ingress, c.generateParentRefs(ingress, c.getGatewayNamesForIngress(ingress)))
+		return ignoreMissingBackendService(err)
+	}
+
+	var gatewayNames []string
+	var err error
+	if c.rootVirtualService != "" {
+		gatewayNames, err = c.getRootGatewayNames()
+	} else {
+		_, gatewayNames, err = c.getClientCertificateGatewaysForIngress(ingress, c.getGatewayNamesForIngress(ingress))
+	}
+	if err != nil {
+		return err
+	}
+
+	gateways, err := c.getGatewaysByName(gatewayNames, ingress.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, name := range getUnknownGateways(gatewayNames, gateways, ingress.Namespace) {
+		*warnings = append(*warnings, fmt.Sprintf("Gateway %q does not exist", name))
+	}
+
+	if _, err := c.generateVirtualService(ingress, nil, gatewayNames); ignoreMissingBackendService(err) != nil {
+		return err
+	}
+
+	if provider, ok := ingress.Annotations[c.identity.key(AuthProviderAnnotation)]; ok {
+		if _, err := c.generateAuthorizationPolicies(ingress, gateways, provider); err != nil {
+			return err
+		}
+	}
+
+	if !c.disableRateLimiting {
+		if _, err := parseRateLimit(c.identity, ingress); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ignoreMissingBackendService ignores the errors about backend services which do not exist,
+// which are reported as warnings since the Services may be created after the Ingress.
+func ignoreMissingBackendService(err error) error {
+	var rerr *reconcileError
+	if errors.As(err, &rerr) && rerr.reason == ReasonMissingBackendService {
+		return nil
+	}
+
+	return err
+}
+
+// validateGatewayNames validates the gateways requested by an Ingress of the namespace,
+// in the <name> or <namespace>/<name> format, against the allowed gateways.
+func validateGatewayNames(gatewayNames []string, currentNamespace string, allowedGateways []string) error {
+	for _, gatewayName := range gatewayNames {
+		if gatewayName == "mesh" {
+			continue
+		}
+
+		namespace, name := currentNamespace, gatewayName
+		if parts := strings.Split(gatewayName, "/"); len(parts) == 2 {
+			namespace, name = parts[0], parts[1]
+			if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+				return fmt.Errorf("invalid namespace of gateway %q: %s", gatewayName, strings.Join(errs, ", "))
+			}
+		} else if len(parts) > 2 {
+			return fmt.Errorf("invalid gateway %q: expected <name> or <namespace>/<name>", gatewayName)
+		}
+
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("invalid name of gateway %q: %s", gatewayName, strings.Join(errs, ", "))
+		}
+
+		if len(allowedGateways) > 0 && !stringInArray(namespace+"/"+name, allowedGateways) && !stringInArray(namespace+"/*", allowedGateways) {
+			return fmt.Errorf("gateway \"%s/%s\" is not allowed", namespace, name)
+		}
+	}
+
+	return nil
+}
+
+// checkPathConflicts returns an error if a path of a host of the Ingress is already routed
+// by another Ingress handled by the controller, as only one of the routes would take effect.
+// Only the routes introduced by an update are checked, given the handled Ingress before
+// the update, so that Ingresses whose conflicts predate the webhook can still be updated.
+func (c *Controller) checkPathConflicts(ingress, old *networkingv1.Ingress) error {
+	routes := ingressRoutes(ingress)
+	if old != nil {
+		for route := range ingressRoutes(old) {
+			delete(routes, route)
+		}
+	}
+
+	hosts := []string{}
+	for _, route := range routes {
+		if !stringInArray(route.host, hosts) {
+			hosts = append(hosts, route.host)
+		}
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		others, err := c.ingressesIndexer.ByIndex(ingressHostIndex, host)
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(others, func(i, j int) bool {
+			return cacheKey(others[i]) < cacheKey(others[j])
+		})
+
+		for _, obj := range others {
+			other, ok := obj.(*networkingv1.Ingress)
+			if !ok || (other.Namespace == ingress.Namespace && other.Name == ingress.Name) {
+				continue
+			}
+
+			if handle, _, err := c.getIngressHandling(c.withAnnotationDefaults(other)); err != nil || !handle {
+				continue
+			}
+
+			for route, path := range ingressRoutes(other) {
+				if _, ok := routes[route]; ok {
+					return fmt.Errorf("path %q of host %q is already routed by Ingress \"%s/%s\"", path.path, path.host, other.Namespace, other.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// cacheKey returns the namespace/name key of an object of the informer caches.
+func cacheKey(obj interface{}) string {
+	key, _ := cache.MetaNamespaceKeyFunc(obj)
+	return key
+}
+
+// ingressHostIndex is the index of the Ingresses by the hosts of their rules.
+const ingressHostIndex = "host"
+
+// ingressHostIndexFunc indexes the Ingresses by the hosts of their routes.
+func ingressHostIndexFunc(obj interface{}) ([]string, error) {
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !ok {
+		return nil, nil
+	}
+
+	hosts := []string{}
+	for _, route := range ingressRoutes(ingress) {
+		if !stringInArray(route.host, hosts) {
+			hosts = append(hosts, route.host)
+		}
+	}
+
+	return hosts, nil
+}
+
+type ingressRoute struct {
+	host string
+	path string
+}
+
+// ingressRoutes returns the routes of the Ingress by host and match,
+// so that the paths matching the same requests are the same route.
+func ingressRoutes(ingress *networkingv1.Ingress) map[string]ingressRoute {
+	routes := map[string]ingressRoute{}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			match := ""
+			if m := createStringMatch(path); m != nil {
+				match = m.String()
+			}
+			routes[host+" "+match] = ingressRoute{host: host, path: path.Path}
+		}
+	}
+
+	return routes
+}
@@ -0,0 +1,380 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"istio.io/api/networking/v1beta1"
+	istionetworkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// testIngress returns an Ingress of the istio ingress class routing the path of the host to the port of the Service.
+func testIngress(name, host, path, service string, port networkingv1.ServiceBackendPort, annotations map[string]string) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	ingressAnnotations := map[string]string{IngressClassAnnotation: "istio"}
+	for k, v := range annotations {
+		ingressAnnotations[k] = v
+	}
+
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "app", Annotations: ingressAnnotations},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: host,
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     path,
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{Name: service, Port: port},
+						},
+					}},
+				}},
+			}},
+		},
+	}
+}
+
+// testGateway returns a Gateway with a plain HTTP server, without TLS settings.
+func testGateway(namespace, name string) *istionetworkingv1beta1.Gateway {
+	return &istionetworkingv1beta1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: v1beta1.Gateway{
+			Selector: map[string]string{"istio": "ingressgateway"},
+			Servers: []*v1beta1.Server{{
+				Port:  &v1beta1.Port{Number: 80, Name: "http", Protocol: "HTTP"},
+				Hosts: []string{"*"},
+			}},
+		},
+	}
+}
+
+func TestValidatingWebhookHandler(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+
+	noHTTP := testIngress("no-http", "d.example.com", "/", "web", http80, nil)
+	noHTTP.Spec.Rules[0].HTTP = nil
+
+	tests := []struct {
+		name     string
+		ingress  *networkingv1.Ingress
+		old      *networkingv1.Ingress
+		allowed  bool
+		message  string
+		warnings []string
+	}{
+		{
+			name:    "valid",
+			ingress: testIngress("valid", "b.example.com", "/", "web", http80, nil),
+			allowed: true,
+		},
+		{
+			name:    "not handled",
+			ingress: testIngress("other", "b.example.com", "/", "web", http80, map[string]string{IngressClassAnnotation: "nginx"}),
+			allowed: true,
+		},
+		{
+			name:    "unknown named port",
+			ingress: testIngress("bad-port", "b.example.com", "/", "web", networkingv1.ServiceBackendPort{Name: "grpc"}, nil),
+			message: `port "grpc" of backend service "app/web" does not exist`,
+		},
+		{
+			name:     "missing backend service",
+			ingress:  testIngress("no-service", "b.example.com", "/", "missing", http80, nil),
+			allowed:  true,
+			warnings: []string{`Backend service "missing" does not exist`},
+		},
+		{
+			name:    "no http rules",
+			ingress: noHTTP,
+			message: "no http definition",
+		},
+		{
+			name:    "malformed gateways annotation",
+			ingress: testIngress("bad-gateway", "c.example.com", "/", "web", http80, map[string]string{GatewaysAnnotation: "a/b/c"}),
+			message: `invalid gateway "a/b/c"`,
+		},
+		{
+			name:    "gateway not allowed",
+			ingress: testIngress("not-allowed", "c.example.com", "/", "web", http80, map[string]string{GatewaysAnnotation: "other/gateway"}),
+			message: `gateway "other/gateway" is not allowed`,
+		},
+		{
+			name:     "unknown gateway",
+			ingress:  testIngress("unknown-gateway", "c.example.com", "/", "web", http80, map[string]string{GatewaysAnnotation: "istio-system/unknown"}),
+			allowed:  true,
+			warnings: []string{`Gateway "istio-system/unknown" does not exist`},
+		},
+		{
+			name:    "invalid ignore annotation",
+			ingress: testIngress("bad-ignore", "c.example.com", "/", "web", http80, map[string]string{IgnoreAnnotation: "maybe"}),
+			message: IgnoreAnnotation,
+		},
+		{
+			name:    "client certificates without a host",
+			ingress: testIngress("mtls-no-host", "", "/", "web", http80, map[string]string{ClientCASecretAnnotation: "partner-mtls-credential"}),
+			message: "client certificates cannot be required for rules without a host",
+		},
+		{
+			name:    "invalid rate limit",
+			ingress: testIngress("bad-rate-limit", "c.example.com", "/", "web", http80, map[string]string{RateLimitRequestsAnnotation: "many"}),
+			message: RateLimitRequestsAnnotation,
+		},
+		{
+			name:    "path conflict",
+			ingress: testIngress("conflict", "a.example.com", "/x/", "web", http80, nil),
+			message: `already routed by Ingress "app/existing"`,
+		},
+		{
+			name:    "update keeping a path conflict",
+			ingress: testIngress("conflict", "a.example.com", "/x/", "web", http80, map[string]string{"team": "web"}),
+			old:     testIngress("conflict", "a.example.com", "/x/", "web", http80, nil),
+			allowed: true,
+		},
+		{
+			name:    "update introducing a path conflict",
+			ingress: testIngress("conflict", "a.example.com", "/x/", "web", http80, nil),
+			old:     testIngress("conflict", "b.example.com", "/x/", "web", http80, nil),
+			message: `already routed by Ingress "app/existing"`,
+		},
+		{
+			name:    "update handling a path conflict",
+			ingress: testIngress("conflict", "a.example.com", "/x/", "web", http80, nil),
+			old:     testIngress("conflict", "a.example.com", "/x/", "web", http80, map[string]string{IngressClassAnnotation: "nginx"}),
+			message: `already routed by Ingress "app/existing"`,
+		},
+	}
+
+	c := newTestController(t, Config{DefaultGateway: "istio-system/ingressgateway", IngressClass: "istio"},
+		testGateway("istio-system", "ingressgateway"),
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8080}}},
+		},
+		testIngress("existing", "a.example.com", "/x", "web", http80, nil),
+	)
+	handler := c.ValidatingWebhookHandler([]string{"istio-system/*"})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw, err := json.Marshal(test.ingress)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := &admissionv1.AdmissionRequest{
+				UID:       "uid",
+				Kind:      metav1.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+				Operation: admissionv1.Create,
+				Namespace: "app",
+				Object:    runtime.RawExtension{Raw: raw},
+			}
+			if test.old != nil {
+				request.Operation = admissionv1.Update
+				if request.OldObject.Raw, err = json.Marshal(test.old); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			body, err := json.Marshal(&admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request:  request,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate-ingress", bytes.NewReader(body)))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+			}
+
+			review := &admissionv1.AdmissionReview{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), review); err != nil {
+				t.Fatal(err)
+			}
+
+			response := review.Response
+			if response.UID != "uid" {
+				t.Errorf("unexpected uid %q", response.UID)
+			}
+			if response.Allowed != test.allowed {
+				t.Errorf("expected allowed=%t, got %t (%v)", test.allowed, response.Allowed, response.Result)
+			}
+			if test.message != "" && (response.Result == nil || !strings.Contains(response.Result.Message, test.message)) {
+				t.Errorf("expected a message containing %q, got %v", test.message, response.Result)
+			}
+			if strings.Join(response.Warnings, "\n") != strings.Join(test.warnings, "\n") {
+				t.Errorf("expected warnings %q, got %q", test.warnings, response.Warnings)
+			}
+		})
+	}
+}
+
+func TestValidatingWebhookHandlerNotSynced(t *testing.T) {
+	c := newTestController(t, Config{})
+	c.gatewaysSynched = func() bool { return false }
+
+	body := `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"uid"}}`
+	recorder := httptest.NewRecorder()
+	c.ValidatingWebhookHandler(nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate-ingress", strings.NewReader(body)))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
+
+func TestValidateGatewayNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		gateways []string
+		allowed  []string
+		err      bool
+	}{
+		{
+			name:     "gateway in the namespace of the ingress",
+			gateways: []string{"gateway"},
+		},
+		{
+			name:     "mesh",
+			gateways: []string{"mesh"},
+			allowed:  []string{"istio-system/ingressgateway"},
+		},
+		{
+			name:     "allowed gateway",
+			gateways: []string{"istio-system/ingressgateway"},
+			allowed:  []string{"istio-system/ingressgateway"},
+		},
+		{
+			name:     "gateway of an allowed namespace",
+			gateways: []string{"istio-system/ingressgateway"},
+			allowed:  []string{"istio-system/*"},
+		},
+		{
+			name:     "gateway not allowed",
+			gateways: []string{"istio-system/ingressgateway", "gateway"},
+			allowed:  []string{"istio-system/ingressgateway"},
+			err:      true,
+		},
+		{
+			name:     "too many separators",
+			gateways: []string{"istio-system/ingressgateway/extra"},
+			err:      true,
+		},
+		{
+			name:     "invalid namespace",
+			gateways: []string{"Istio_System/ingressgateway"},
+			err:      true,
+		},
+		{
+			name:     "invalid name",
+			gateways: []string{"istio-system/Ingress_Gateway"},
+			err:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateGatewayNames(test.gateways, "app", test.allowed)
+			if test.err && err == nil {
+				t.Errorf("expected an error")
+			} else if !test.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestIngressRoutes(t *testing.T) {
+	http80 := networkingv1.ServiceBackendPort{Name: "http"}
+	exact := networkingv1.PathTypeExact
+
+	withPaths := func(ingress *networkingv1.Ingress, paths ...networkingv1.HTTPIngressPath) *networkingv1.Ingress {
+		ingress.Spec.Rules[0].HTTP.Paths = append(ingress.Spec.Rules[0].HTTP.Paths, paths...)
+		return ingress
+	}
+	withRule := func(ingress *networkingv1.Ingress, rule networkingv1.IngressRule) *networkingv1.Ingress {
+		ingress.Spec.Rules = append(ingress.Spec.Rules, rule)
+		return ingress
+	}
+
+	tests := []struct {
+		name    string
+		ingress *networkingv1.Ingress
+		routes  []ingressRoute
+		hosts   []string
+	}{
+		{
+			name:    "single path",
+			ingress: testIngress("web", "a.example.com", "/", "web", http80, nil),
+			routes:  []ingressRoute{{host: "a.example.com", path: "/"}},
+			hosts:   []string{"a.example.com"},
+		},
+		{
+			name:    "prefix paths matching the same requests",
+			ingress: withPaths(testIngress("web", "a.example.com", "/api", "web", http80, nil), testIngress("web", "a.example.com", "/api/", "web", http80, nil).Spec.Rules[0].HTTP.Paths[0]),
+			routes:  []ingressRoute{{host: "a.example.com", path: "/api/"}},
+			hosts:   []string{"a.example.com"},
+		},
+		{
+			name:    "exact and prefix paths",
+			ingress: withPaths(testIngress("web", "a.example.com", "/api", "web", http80, nil), networkingv1.HTTPIngressPath{Path: "/api", PathType: &exact, Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "web", Port: http80}}}),
+			routes:  []ingressRoute{{host: "a.example.com", path: "/api"}, {host: "a.example.com", path: "/api"}},
+			hosts:   []string{"a.example.com"},
+		},
+		{
+			name:    "rule without a host",
+			ingress: testIngress("web", "", "/", "web", http80, nil),
+			routes:  []ingressRoute{{host: "*", path: "/"}},
+			hosts:   []string{"*"},
+		},
+		{
+			name:    "rule without http",
+			ingress: withRule(testIngress("web", "a.example.com", "/", "web", http80, nil), networkingv1.IngressRule{Host: "b.example.com"}),
+			routes:  []ingressRoute{{host: "a.example.com", path: "/"}},
+			hosts:   []string{"a.example.com"},
+		},
+		{
+			name:    "multiple hosts",
+			ingress: withRule(testIngress("web", "a.example.com", "/", "web", http80, nil), testIngress("web", "b.example.com", "/", "web", http80, nil).Spec.Rules[0]),
+			routes:  []ingressRoute{{host: "a.example.com", path: "/"}, {host: "b.example.com", path: "/"}},
+			hosts:   []string{"a.example.com", "b.example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routes := []ingressRoute{}
+			for _, route := range ingressRoutes(test.ingress) {
+				routes = append(routes, route)
+			}
+			sort.Slice(routes, func(i, j int) bool {
+				return routes[i].host+" "+routes[i].path < routes[j].host+" "+routes[j].path
+			})
+			if diff := cmp.Diff(test.routes, routes, cmp.AllowUnexported(ingressRoute{})); diff != "" {
+				t.Errorf("unexpected routes (-want +got):\n%s", diff)
+			}
+
+			hosts, err := ingressHostIndexFunc(test.ingress)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sort.Strings(hosts)
+			if diff := cmp.Diff(test.hosts, hosts); diff != "" {
+				t.Errorf("unexpected hosts (-want +got):\n%s", diff)
+			}
+		})
+	}
+}